@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// AccessUser is a team member with its own linux account in the jumpbox
+type AccessUser struct {
+	Name string   `json:"name"`
+	Keys []string `json:"keys"`
+}
+
+var linuxUserRegex = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// addUserScript creates the user when missing and replaces its authorized_keys with stdin
+const addUserScript = `set -e
+id -u %[1]s >/dev/null 2>&1 || sudo useradd --create-home --shell /bin/bash %[1]s
+home=$(getent passwd %[1]s | cut -d: -f6)
+sudo install -d -m 0700 -o %[1]s -g %[1]s "$home/.ssh"
+sudo tee "$home/.ssh/authorized_keys" >/dev/null
+sudo chown %[1]s:%[1]s "$home/.ssh/authorized_keys"
+sudo chmod 0600 "$home/.ssh/authorized_keys"
+`
+
+const delUserScript = `set -e
+if id -u %[1]s >/dev/null 2>&1; then sudo userdel --remove %[1]s; fi
+`
+
+func newAccessCmd(ctx context.Context) *cobra.Command {
+	accessCmd := &cobra.Command{
+		Use:   "access",
+		Short: "Manage users with access to the Jumpbox",
+	}
+	accessCmd.AddCommand(
+		newAccessGrantCmd(ctx),
+		newAccessRevokeCmd(ctx),
+		newAccessListCmd(ctx),
+	)
+	return accessCmd
+}
+
+func newAccessGrantCmd(ctx context.Context) *cobra.Command {
+	grantCmd := &cobra.Command{
+		Use:   "grant",
+		Short: "Grant a user access to the Jumpbox with its public key",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return GrantAccess(ctx)
+		}}
+	grantCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	grantCmd.Flags().StringVarP(&options.accessUser, "user", "u", "", "linux user to be created for the team member")
+	grantCmd.Flags().StringVarP(&options.accessKeyPath, "key", "k", "", "Path to the user ssh public key")
+	_ = grantCmd.MarkFlagRequired("namespace")
+	_ = grantCmd.MarkFlagRequired("user")
+	_ = grantCmd.MarkFlagRequired("key")
+
+	return grantCmd
+}
+
+func newAccessRevokeCmd(ctx context.Context) *cobra.Command {
+	revokeCmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke a user access and remove its account from the Jumpbox",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RevokeAccess(ctx)
+		}}
+	revokeCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	revokeCmd.Flags().StringVarP(&options.accessUser, "user", "u", "", "linux user to be removed")
+	_ = revokeCmd.MarkFlagRequired("namespace")
+	_ = revokeCmd.MarkFlagRequired("user")
+
+	return revokeCmd
+}
+
+func newAccessListCmd(ctx context.Context) *cobra.Command {
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List users with access to the Jumpbox",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ListAccess(ctx)
+		}}
+	listCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	_ = listCmd.MarkFlagRequired("namespace")
+
+	return listCmd
+}
+
+// checkAccessUser validates a team member name. The login user, the image default user and root hold the keys the
+// plugin connects with, so their accounts can't be replaced or removed
+func checkAccessUser(name string, loginUser string, cloudUser string) error {
+	if !linuxUserRegex.MatchString(name) {
+		return errors.Errorf("invalid user name %q", name)
+	}
+	switch name {
+	case "root", loginUser, cloudUser:
+		return errors.Errorf("user %s is reserved, it is the jumpbox login user, the image default user or root", name)
+	}
+	return nil
+}
+
+// checkJumpboxAccessUser validates a team member name against the users of the jumpbox
+func checkJumpboxAccessUser(ctx context.Context, name string) error {
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return err
+	}
+	admin, err := cloudUser(ctx, spec.ImageName, loadConfigOrEmpty())
+	if err != nil {
+		return err
+	}
+	return checkAccessUser(name, spec.User, admin)
+}
+
+// GrantAccess adds the user to the jumpbox roster and creates its account over ssh
+func GrantAccess(ctx context.Context) error {
+	err := checkJumpboxAccessUser(ctx, options.accessUser)
+	if err != nil {
+		return err
+	}
+	pub, err := os.ReadFile(options.accessKeyPath)
+	if err != nil {
+		return errors.Wrap(err, "error reading public key")
+	}
+	keys, err := parseAuthorizedKeys(pub)
+	if err != nil {
+		return err
+	}
+
+	err = updateAccessRoster(ctx, func(roster map[string]string) {
+		roster[options.accessUser] = strings.Join(keys, "\n")
+	})
+	if err != nil {
+		return err
+	}
+
+	client, err := dialJumpboxAdmin(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "roster updated but jumpbox is not reachable, access will be applied on rebuild")
+	}
+	defer func(client *ssh.Client) {
+		_ = client.Close()
+	}(client)
+
+	_, err = runRemote(client, fmt.Sprintf(addUserScript, options.accessUser), strings.NewReader(strings.Join(keys, "\n")+"\n"))
+	if err != nil {
+		return errors.WithMessage(err, "error creating user in jumpbox")
+	}
+
+	fmt.Printf("Granted access to %s in %s\n", options.accessUser, options.Name)
+	return nil
+}
+
+// RevokeAccess removes the user from the jumpbox roster and deletes its account over ssh
+func RevokeAccess(ctx context.Context) error {
+	err := checkJumpboxAccessUser(ctx, options.accessUser)
+	if err != nil {
+		return err
+	}
+	found := false
+	err = updateAccessRoster(ctx, func(roster map[string]string) {
+		_, found = roster[options.accessUser]
+		delete(roster, options.accessUser)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.Errorf("user %s has no access to %s", options.accessUser, options.Name)
+	}
+
+	client, err := dialJumpboxAdmin(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "roster updated but jumpbox is not reachable, access will be removed on rebuild")
+	}
+	defer func(client *ssh.Client) {
+		_ = client.Close()
+	}(client)
+
+	_, err = runRemote(client, fmt.Sprintf(delUserScript, options.accessUser), nil)
+	if err != nil {
+		return errors.WithMessage(err, "error removing user from jumpbox")
+	}
+
+	fmt.Printf("Revoked access of %s in %s\n", options.accessUser, options.Name)
+	return nil
+}
+
+// ListAccess prints the jumpbox roster with the fingerprint of each user key
+func ListAccess(ctx context.Context) error {
+	users, err := getAccessUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "USER\tKEY")
+	for _, user := range users {
+		for _, key := range user.Keys {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", user.Name, keyFingerprint(key))
+		}
+	}
+	return w.Flush()
+}
+
+// getAccessUsers reads the jumpbox roster. A missing roster means no extra users
+func getAccessUsers(ctx context.Context) ([]AccessUser, error) {
+	cm, err := c.CoreV1().ConfigMaps(options.Namespace).Get(ctx, options.accessConfigName, v1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "error getting access roster")
+	}
+
+	users := make([]AccessUser, 0, len(cm.Data))
+	for name, keys := range cm.Data {
+		users = append(users, AccessUser{Name: name, Keys: strings.Split(keys, "\n")})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+// syncAccessUsers makes the roster the users given and, when remote, creates and removes their accounts over ssh. It
+// returns whether the roster changed
+func syncAccessUsers(ctx context.Context, users []AccessUser, remote bool) (bool, error) {
+	current, err := getAccessUsers(ctx)
+	if err != nil {
+		return false, err
+	}
+	added, removed := accessChanges(current, users)
+	if len(added) == 0 && len(removed) == 0 {
+		return false, nil
+	}
+	err = updateAccessRoster(ctx, func(roster map[string]string) {
+		for name := range roster {
+			delete(roster, name)
+		}
+		for _, user := range users {
+			roster[user.Name] = strings.Join(user.Keys, "\n")
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	if !remote {
+		return true, nil
+	}
+
+	client, err := dialJumpboxAdmin(ctx)
+	if err != nil {
+		return true, errors.WithMessage(err, "roster updated but jumpbox is not reachable, access will be applied on rebuild")
+	}
+	defer func(client *ssh.Client) {
+		_ = client.Close()
+	}(client)
+	for _, user := range added {
+		_, err = runRemote(client, fmt.Sprintf(addUserScript, user.Name), strings.NewReader(strings.Join(user.Keys, "\n")+"\n"))
+		if err != nil {
+			return true, errors.WithMessage(err, "error creating user in jumpbox")
+		}
+		fmt.Printf("Granted access to %s in %s\n", user.Name, options.Name)
+	}
+	for _, name := range removed {
+		_, err = runRemote(client, fmt.Sprintf(delUserScript, name), nil)
+		if err != nil {
+			return true, errors.WithMessage(err, "error removing user from jumpbox")
+		}
+		fmt.Printf("Revoked access of %s in %s\n", name, options.Name)
+	}
+	return true, nil
+}
+
+// accessChanges returns the users to add or update, and the names of the users to remove, to go from current to desired
+func accessChanges(current []AccessUser, desired []AccessUser) ([]AccessUser, []string) {
+	keys := map[string]string{}
+	for _, user := range current {
+		keys[user.Name] = strings.Join(user.Keys, "\n")
+	}
+	var added []AccessUser
+	for _, user := range desired {
+		k, ok := keys[user.Name]
+		if !ok || k != strings.Join(user.Keys, "\n") {
+			added = append(added, user)
+		}
+		delete(keys, user.Name)
+	}
+	removed := make([]string, 0, len(keys))
+	for name := range keys {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	return added, removed
+}
+
+// updateAccessRoster applies update to the roster ConfigMap, creating it when missing
+func updateAccessRoster(ctx context.Context, update func(roster map[string]string)) error {
+	cms := c.CoreV1().ConfigMaps(options.Namespace)
+	cm, err := cms.Get(ctx, options.accessConfigName, v1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return errors.Wrap(err, "error getting access roster")
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      options.accessConfigName,
+				Namespace: options.Namespace,
+				Labels: map[string]string{
+					"jumpbox": options.Name,
+				},
+			},
+			Data: map[string]string{},
+		}
+		update(cm.Data)
+		_, err = cms.Create(ctx, cm, v1.CreateOptions{})
+		return errors.Wrap(err, "error creating access roster")
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	update(cm.Data)
+	_, err = cms.Update(ctx, cm, v1.UpdateOptions{})
+	return errors.Wrap(err, "error updating access roster")
+}
+
+// parseAuthorizedKeys validates an authorized_keys formatted file and returns one key per entry
+func parseAuthorizedKeys(data []byte) ([]string, error) {
+	var keys []string
+	for len(data) > 0 {
+		pub, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			if len(keys) > 0 && strings.TrimSpace(string(data)) == "" {
+				break
+			}
+			return nil, errors.Wrap(err, "error parsing public key")
+		}
+		keys = append(keys, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))))
+		data = rest
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no public key found")
+	}
+	return keys, nil
+}
+
+func keyFingerprint(key string) string {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return "invalid key"
+	}
+	return ssh.FingerprintSHA256(pub)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	simpleFake "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func Test_parseAuthorizedKeys(t *testing.T) {
+	_, pub, err := MakeSSHKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, pub2, err := MakeSSHKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantKeys int
+		wantErr  bool
+	}{
+		{
+			name:     "single-key",
+			data:     pub,
+			wantKeys: 1,
+		},
+		{
+			name:     "two-keys-trailing-newline",
+			data:     append(append(append([]byte{}, pub...), pub2...), '\n'),
+			wantKeys: 2,
+		},
+		{
+			name:    "empty",
+			data:    []byte(""),
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			data:    []byte("not a key"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseAuthorizedKeys(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseAuthorizedKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(keys) != tt.wantKeys {
+				t.Errorf("parseAuthorizedKeys() got %d keys, want %d", len(keys), tt.wantKeys)
+			}
+		})
+	}
+}
+
+func Test_checkAccessUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "bob"},
+		{name: "Bob", wantErr: true},
+		{name: "root", wantErr: true},
+		{name: "alice", wantErr: true},
+		{name: "ubuntu", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkAccessUser(tt.name, "alice", "ubuntu"); (err != nil) != tt.wantErr {
+				t.Errorf("checkAccessUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_updateAccessRoster(t *testing.T) {
+	ctx := context.Background()
+	setClient(t, simpleFake.NewSimpleClientset())
+	setOptions(t, &VMOptions{Namespace: "test"})
+	setup([]string{"jumpbox-1"})
+
+	tests := []struct {
+		name      string
+		update    func(roster map[string]string)
+		wantUsers []string
+	}{
+		{
+			name: "grant-creates-roster",
+			update: func(roster map[string]string) {
+				roster["alice"] = "ssh-rsa AAAA"
+			},
+			wantUsers: []string{"alice"},
+		},
+		{
+			name: "grant-second-user",
+			update: func(roster map[string]string) {
+				roster["bob"] = "ssh-rsa BBBB"
+			},
+			wantUsers: []string{"alice", "bob"},
+		},
+		{
+			name: "revoke",
+			update: func(roster map[string]string) {
+				delete(roster, "alice")
+			},
+			wantUsers: []string{"bob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := updateAccessRoster(ctx, tt.update); err != nil {
+				t.Fatalf("updateAccessRoster() error = %v", err)
+			}
+			users, err := getAccessUsers(ctx)
+			if err != nil {
+				t.Fatalf("getAccessUsers() error = %v", err)
+			}
+			if len(users) != len(tt.wantUsers) {
+				t.Fatalf("getAccessUsers() got %v, want %v", users, tt.wantUsers)
+			}
+			for i, user := range users {
+				if user.Name != tt.wantUsers[i] {
+					t.Errorf("getAccessUsers() got %s, want %s", user.Name, tt.wantUsers[i])
+				}
+			}
+		})
+	}
+}
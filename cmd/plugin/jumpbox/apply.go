@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	"sort"
+)
+
+// specChange is a field of the jumpbox spec that differs between the live jumpbox and the desired spec
+type specChange struct {
+	Field string
+	From  interface{}
+	To    interface{}
+	// Recreate tells the VM must be recreated to apply the change. The other changes are applied in place
+	Recreate bool
+	// PowerCycle tells the change is applied by the VM Service on the next power cycle
+	PowerCycle bool
+}
+
+// intentSpecFields record how the image and class were resolved. Changing them alone doesn't change the jumpbox
+var intentSpecFields = map[string]bool{"version": true, "os": true, "cpus": true, "memory": true}
+
+// jsonValues returns the fields of an object as they are encoded in json
+func jsonValues(obj interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling")
+	}
+	values := map[string]interface{}{}
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading values")
+	}
+	return values, nil
+}
+
+// specChanges compares the live spec with the desired one, field by field
+func specChanges(live *JumpboxSpec, desired *JumpboxSpec) ([]specChange, error) {
+	from, err := jsonValues(live)
+	if err != nil {
+		return nil, err
+	}
+	to, err := jsonValues(desired)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]bool{}
+	for field := range from {
+		fields[field] = true
+	}
+	for field := range to {
+		fields[field] = true
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	var changes []specChange
+	for _, field := range names {
+		if intentSpecFields[field] || reflect.DeepEqual(from[field], to[field]) {
+			continue
+		}
+		change := specChange{Field: field, From: from[field], To: to[field], Recreate: true}
+		switch field {
+		case "className":
+			change.Recreate = false
+			change.PowerCycle = true
+		case "ports":
+			change.Recreate = false
+		case "disk":
+			change.Recreate = !diskResizeOnly(live.Disk, desired.Disk)
+		case "volumes":
+			change.Recreate = !volumesResizeOnly(live.Volumes, desired.Volumes)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// diskResizeOnly tells the workspace disks differ only in their size
+func diskResizeOnly(live *DiskSettings, desired *DiskSettings) bool {
+	if live == nil || desired == nil {
+		return false
+	}
+	a, b := *live, *desired
+	a.Size, b.Size = "", ""
+	return a == b
+}
+
+// volumesResizeOnly tells the extra volumes differ only in their sizes
+func volumesResizeOnly(live []VolumeSettings, desired []VolumeSettings) bool {
+	if len(live) != len(desired) {
+		return false
+	}
+	for i := range live {
+		a, b := live[i], desired[i]
+		a.Size, b.Size = "", ""
+		if a != b {
+			return false
+		}
+	}
+	return true
+}
+
+func recreateNeeded(changes []specChange) bool {
+	for _, change := range changes {
+		if change.Recreate {
+			return true
+		}
+	}
+	return false
+}
+
+func changed(changes []specChange, field string) bool {
+	for _, change := range changes {
+		if change.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply creates the jumpbox of the manifest, or updates it to match the manifest. Class, port and disk size changes
+// are applied in place, and the VM is recreated for the other changes, keeping its volumes and ssh keys
+func Apply(ctx context.Context, manifest *JumpboxManifest) error {
+	manifest.applyTo(options)
+	setup([]string{manifest.Metadata.Name})
+	err := resolveIntentOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	live, err := getJumpboxSpec(ctx)
+	if apierrors.IsNotFound(errors.Cause(err)) {
+		err = prepareCreate(ctx)
+		if err != nil {
+			return err
+		}
+		if manifest.Spec.AccessUsers != nil {
+			_, err = syncAccessUsers(ctx, manifest.Spec.AccessUsers, false)
+			if err != nil {
+				return err
+			}
+		}
+		return CreateJumpBox(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	desired, err := desiredSpec(ctx)
+	if err != nil {
+		return err
+	}
+	changes, err := specChanges(live, desired)
+	if err != nil {
+		return err
+	}
+	recreate := recreateNeeded(changes)
+	for _, change := range changes {
+		fmt.Printf("%s: %s -> %s%s\n", change.Field, changeValue(change.From), changeValue(change.To), changeNote(change))
+	}
+
+	grown, err := syncClaims(ctx)
+	if err != nil {
+		return err
+	}
+	if changed(changes, "ports") {
+		err = updateServicePorts(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	rosterChanged := false
+	if manifest.Spec.AccessUsers != nil {
+		rosterChanged, err = syncAccessUsers(ctx, manifest.Spec.AccessUsers, !recreate)
+		if err != nil {
+			return err
+		}
+	}
+	if len(changes) == 0 {
+		if !rosterChanged {
+			fmt.Printf("Jumpbox %s is up to date\n", options.Name)
+		}
+		return nil
+	}
+
+	if recreate {
+		err = recreateVM(ctx)
+		if err != nil {
+			return err
+		}
+	} else {
+		var vmSpec map[string]interface{}
+		if changed(changes, "className") {
+			vmSpec = map[string]interface{}{"className": desired.ClassName}
+		}
+		err = patchJumpboxSpec(ctx, desired, vmSpec)
+		if err != nil {
+			return errors.WithMessage(err, "error updating vm")
+		}
+	}
+	if len(grown) > 0 {
+		err = growFilesystems(ctx, grown)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Applied jumpbox %s\n", options.Name)
+	if !recreate && changed(changes, "className") {
+		fmt.Println("Power cycle the jumpbox to apply the class")
+	}
+	return nil
+}
+
+// desiredSpec renders the options of an existing jumpbox, keeping its ssh keys, and returns the spec they give
+func desiredSpec(ctx context.Context) (*JumpboxSpec, error) {
+	err := loadSSHKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if options.AccessUsers == nil {
+		options.AccessUsers, err = getAccessUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = validateUser()
+	if err != nil {
+		return nil, err
+	}
+	err = resolvePorts()
+	if err != nil {
+		return nil, err
+	}
+	err = resolveNICs()
+	if err != nil {
+		return nil, err
+	}
+	err = resolveTransport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = buildUserdata()
+	if err != nil {
+		return nil, err
+	}
+	err = resolveBootstrap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = validateOfflineTools()
+	if err != nil {
+		return nil, err
+	}
+	return newJumpboxSpec(), nil
+}
+
+func changeValue(value interface{}) string {
+	if value == nil {
+		return "<none>"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func changeNote(change specChange) string {
+	switch {
+	case change.Recreate:
+		return " (recreate)"
+	case change.PowerCycle:
+		return " (power cycle)"
+	}
+	return ""
+}
+
+// syncClaims creates the missing PVCs of the workspace disk and volumes and grows the smaller ones, and returns the
+// filesystem labels of the disks grown
+func syncClaims(ctx context.Context) ([]string, error) {
+	type claim struct {
+		name         string
+		label        string
+		size         string
+		storageClass string
+	}
+	claims := []claim{{name: options.pvcName, label: workspaceLabel, size: options.Disk.Size, storageClass: options.StorageClassName}}
+	for _, volume := range options.Volumes {
+		claims = append(claims, claim{name: volumePVCName(volume.Name), label: volume.Name, size: volume.Size, storageClass: volume.StorageClass})
+	}
+
+	disks, err := newDisks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = checkNewDiskSizes(disks)
+	if err != nil {
+		return nil, err
+	}
+
+	var grown []string
+	for _, claim := range claims {
+		_, err := c.CoreV1().PersistentVolumeClaims(options.Namespace).Get(ctx, claim.name, v1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			err = createClaim(ctx, claim.name, claim.size, claim.storageClass)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting pvc")
+		}
+
+		size, err := parseDiskSize(claim.size)
+		if err != nil {
+			return nil, err
+		}
+		before, err := resizePVC(ctx, claim.name, size)
+		if err != nil {
+			return nil, err
+		}
+		if before.Cmp(size) < 0 {
+			err = waitResize(ctx, claim.name, size)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Printf("Persistent Volume %s: %s -> %s\n", claim.name, before.String(), size.String())
+			grown = append(grown, claim.label)
+		}
+	}
+	return grown, nil
+}
+
+// growFilesystems grows the filesystems with the labels given over ssh
+func growFilesystems(ctx context.Context, labels []string) error {
+	client, err := dialJumpboxAdmin(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "the disks were resized but the jumpbox is not reachable, run expand-disk to grow the filesystems")
+	}
+	defer func(client *ssh.Client) {
+		_ = client.Close()
+	}(client)
+
+	for _, label := range labels {
+		fsBefore, fsAfter, err := growFilesystem(client, label)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Filesystem %s: %s -> %s\n", label, formatBytes(fsBefore), formatBytes(fsAfter))
+	}
+	return nil
+}
+
+// updateServicePorts sets the ports of the jumpbox VirtualMachineService
+func updateServicePorts(ctx context.Context) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"ports": servicePorts(),
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "err marshaling")
+	}
+	_, err = dynamicClient.Resource(gvrSvc).Namespace(options.Namespace).Patch(ctx, options.svcName, types.MergePatchType, payload, v1.PatchOptions{})
+	if err != nil {
+		return errors.Wrap(err, "error updating service ports")
+	}
+	fmt.Printf("Updated VM service %s ports\n", options.svcName)
+	return nil
+}
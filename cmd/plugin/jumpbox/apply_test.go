@@ -0,0 +1,153 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_specChanges(t *testing.T) {
+	live := func() *JumpboxSpec {
+		return &JumpboxSpec{
+			Version:          specVersion,
+			ImageName:        "ubuntu-2004",
+			ClassName:        "best-effort-small",
+			StorageClassName: "gold",
+			NetworkType:      networkTypeNSXT,
+			User:             "operator",
+			Disk:             &DiskSettings{Size: "64Gi", FSType: "ext4", MountPath: "/home/operator"},
+			Volumes:          []VolumeSettings{{Name: "scratch", Size: "100Gi", StorageClass: "gold", MountPath: "/scratch", FSType: "ext4"}},
+			OS:               "ubuntu",
+		}
+	}
+	tests := []struct {
+		name string
+		edit func(spec *JumpboxSpec)
+		want []specChange
+	}{
+		{
+			name: "unchanged",
+			edit: func(spec *JumpboxSpec) {},
+		},
+		{
+			name: "intent",
+			edit: func(spec *JumpboxSpec) { spec.OS = "" },
+		},
+		{
+			name: "class",
+			edit: func(spec *JumpboxSpec) { spec.ClassName = "best-effort-large" },
+			want: []specChange{{Field: "className", From: "best-effort-small", To: "best-effort-large", PowerCycle: true}},
+		},
+		{
+			name: "disk-size",
+			edit: func(spec *JumpboxSpec) { spec.Disk.Size = "128Gi" },
+			want: []specChange{{
+				Field: "disk",
+				From:  map[string]interface{}{"size": "64Gi", "fsType": "ext4", "mountPath": "/home/operator"},
+				To:    map[string]interface{}{"size": "128Gi", "fsType": "ext4", "mountPath": "/home/operator"},
+			}},
+		},
+		{
+			name: "disk-mount",
+			edit: func(spec *JumpboxSpec) { spec.Disk.MountPath = "/workspace" },
+			want: []specChange{{
+				Field:    "disk",
+				From:     map[string]interface{}{"size": "64Gi", "fsType": "ext4", "mountPath": "/home/operator"},
+				To:       map[string]interface{}{"size": "64Gi", "fsType": "ext4", "mountPath": "/workspace"},
+				Recreate: true,
+			}},
+		},
+		{
+			name: "volume-added",
+			edit: func(spec *JumpboxSpec) {
+				spec.Volumes = append(spec.Volumes, VolumeSettings{Name: "data", Size: "10Gi", StorageClass: "gold", MountPath: "/data", FSType: "ext4"})
+			},
+		},
+		{
+			name: "image-and-ports",
+			edit: func(spec *JumpboxSpec) {
+				spec.ImageName = "ubuntu-2204"
+				spec.Ports = []ServicePort{{Name: "http", Port: 80, TargetPort: 80, Protocol: "TCP"}}
+			},
+			want: []specChange{
+				{Field: "imageName", From: "ubuntu-2004", To: "ubuntu-2204", Recreate: true},
+				{Field: "ports", To: []interface{}{map[string]interface{}{"name": "http", "port": float64(80), "targetPort": float64(80), "protocol": "TCP"}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := live()
+			tt.edit(desired)
+			got, err := specChanges(live(), desired)
+			if err != nil {
+				t.Fatalf("specChanges() error = %v", err)
+			}
+			if tt.name == "volume-added" {
+				if len(got) != 1 || got[0].Field != "volumes" || !got[0].Recreate {
+					t.Errorf("specChanges() got = %+v, want volumes recreate", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("specChanges() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_accessChanges(t *testing.T) {
+	current := []AccessUser{
+		{Name: "alice", Keys: []string{"ssh-ed25519 AAAA alice"}},
+		{Name: "bob", Keys: []string{"ssh-ed25519 BBBB bob"}},
+		{Name: "carol", Keys: []string{"ssh-ed25519 CCCC carol"}},
+	}
+	desired := []AccessUser{
+		{Name: "alice", Keys: []string{"ssh-ed25519 AAAA alice"}},
+		{Name: "bob", Keys: []string{"ssh-ed25519 BBBB bob", "ssh-ed25519 BBB2 bob"}},
+		{Name: "dave", Keys: []string{"ssh-ed25519 DDDD dave"}},
+	}
+	added, removed := accessChanges(current, desired)
+	if !reflect.DeepEqual(added, desired[1:]) {
+		t.Errorf("accessChanges() added = %+v, want %+v", added, desired[1:])
+	}
+	if !reflect.DeepEqual(removed, []string{"carol"}) {
+		t.Errorf("accessChanges() removed = %v, want [carol]", removed)
+	}
+}
+
+func Test_resolvePorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []string
+		want    []ServicePort
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			flags: []string{"name=http,port=80"},
+			want:  []ServicePort{{Name: "http", Port: 80, TargetPort: 80, Protocol: "TCP"}},
+		},
+		{
+			name:  "target-and-protocol",
+			flags: []string{"name=dns,port=53,target-port=5353,protocol=udp"},
+			want:  []ServicePort{{Name: "dns", Port: 53, TargetPort: 5353, Protocol: "UDP"}},
+		},
+		{name: "ssh-name", flags: []string{"name=ssh,port=2222"}, wantErr: true},
+		{name: "ssh-port", flags: []string{"name=alt,port=22"}, wantErr: true},
+		{name: "out-of-range", flags: []string{"name=http,port=70000"}, wantErr: true},
+		{name: "protocol", flags: []string{"name=http,port=80,protocol=SCTP"}, wantErr: true},
+		{name: "unknown-field", flags: []string{"name=http,port=80,host=a"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{portFlags: tt.flags})
+			err := resolvePorts()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolvePorts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(options.Ports, tt.want) {
+				t.Errorf("resolvePorts() got = %+v, want %+v", options.Ports, tt.want)
+			}
+		})
+	}
+}
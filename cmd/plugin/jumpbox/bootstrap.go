@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"os"
+	"sigs.k8s.io/yaml"
+	"sort"
+)
+
+// bootstrap resources holding the VM metadata. auto uses a Secret when the supervisor supports it
+const (
+	bootstrapAuto      = "auto"
+	bootstrapSecret    = "secret"
+	bootstrapConfigMap = "configmap"
+)
+
+var gvrCRD = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// vmCRDName is the VirtualMachine CRD, read to find if vmMetadata.secretName is supported
+const vmCRDName = "virtualmachines.vmoperator.vmware.com"
+
+// secretMetadataSupported checks the VirtualMachine CRD schema for vmMetadata.secretName. Supervisors that don't let
+// the user read the CRD are treated as not supporting it
+func secretMetadataSupported(ctx context.Context) bool {
+	crd, err := dynamicClient.Resource(gvrCRD).Get(ctx, vmCRDName, v1.GetOptions{})
+	if err != nil {
+		return false
+	}
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, version := range versions {
+		version, ok := version.(map[string]interface{})
+		if !ok || version["name"] != gvrVM.Version {
+			continue
+		}
+		_, found, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema", "properties", "spec",
+			"properties", "vmMetadata", "properties", "secretName")
+		return found
+	}
+	return false
+}
+
+// resolveBootstrap validates --bootstrap, selecting it when auto. Secret values always need a Secret
+func resolveBootstrap(ctx context.Context) error {
+	switch options.Bootstrap {
+	case "", bootstrapAuto:
+		options.Bootstrap = bootstrapConfigMap
+		if secretMetadataSupported(ctx) {
+			options.Bootstrap = bootstrapSecret
+		}
+	case bootstrapSecret:
+		if !secretMetadataSupported(ctx) {
+			return errors.New("the supervisor VirtualMachine API does not support vmMetadata.secretName")
+		}
+	case bootstrapConfigMap:
+	default:
+		return errors.Errorf("invalid bootstrap %q. valid values are %s, %s and %s", options.Bootstrap, bootstrapAuto, bootstrapSecret, bootstrapConfigMap)
+	}
+
+	if len(options.Secrets) > 0 && options.Bootstrap != bootstrapSecret {
+		return errors.New("secret values need a Secret bootstrap, which is not supported by the supervisor VirtualMachine API")
+	}
+	return nil
+}
+
+func addSecretValuesFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&options.secretValuesPath, "secret-values", "", "", "Path to a yaml file with secret template values, like tokens and passwords")
+	cmd.Flags().StringArrayVarP(&options.setSecrets, "set-secret", "", nil, "Set a secret template value, key=value. Can be repeated")
+}
+
+// loadSecrets builds the secret template values from the --secret-values file and --set-secret flags. Secret values
+// are available as `.Secrets.<key>` and are never persisted in the jumpbox spec
+func loadSecrets() (map[string]interface{}, error) {
+	secrets := map[string]interface{}{}
+	if options.secretValuesPath != "" {
+		data, err := os.ReadFile(localPath(options.secretValuesPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading secret values file")
+		}
+		err = yaml.Unmarshal(data, &secrets)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing secret values file")
+		}
+	}
+	for _, set := range options.setSecrets {
+		err := setValue(secrets, set)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return secrets, nil
+}
+
+// secretKeys are the top level keys of the secret values, recorded in the spec without their values
+func secretKeys(secrets map[string]interface{}) []string {
+	var keys []string
+	for k := range secrets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkSecretKeys fails when secret values used to create the jumpbox are not given again
+func checkSecretKeys(keys []string) error {
+	var missing []string
+	for _, k := range keys {
+		if _, ok := options.Secrets[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("missing secret values %v. set them with --secret-values or --set-secret", missing)
+	}
+	return nil
+}
+
+// newBootstrapSecret builds the Secret with the VM metadata
+func newBootstrapSecret() (*corev1.Secret, error) {
+	data, err := metadataData()
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		TypeMeta: v1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      options.bootstrapSecretName,
+			Namespace: options.Namespace,
+			Labels: map[string]string{
+				"jumpbox": options.Name,
+			},
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: data,
+	}, nil
+}
+
+// createBootstrap creates the resource with the VM metadata
+func createBootstrap(ctx context.Context) error {
+	if options.Bootstrap != bootstrapSecret {
+		return createConfigMap(ctx)
+	}
+	secret, err := newBootstrapSecret()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.CoreV1().Secrets(options.Namespace).Create(ctx, secret, v1.CreateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "err creating bootstrap secret")
+	}
+	return nil
+}
+
+// deleteBootstrap deletes the VM metadata ConfigMap and Secret
+func deleteBootstrap(ctx context.Context) error {
+	err := c.CoreV1().ConfigMaps(options.Namespace).Delete(ctx, options.configName, v1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "error deleting Config")
+	}
+	err = c.CoreV1().Secrets(options.Namespace).Delete(ctx, options.bootstrapSecretName, v1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "error deleting bootstrap secret")
+	}
+	fmt.Println("VM Config deleted")
+	return nil
+}
+
+// vmMetadata references the bootstrap resource in the VM
+func vmMetadata() *v1alpha1.VirtualMachineMetadata {
+	metadata := &v1alpha1.VirtualMachineMetadata{
+		Transport: v1alpha1.VirtualMachineMetadataTransport(metadataTransport()),
+	}
+	if options.Bootstrap == bootstrapSecret {
+		metadata.SecretName = options.bootstrapSecretName
+	} else {
+		metadata.ConfigMapName = options.configName
+	}
+	return metadata
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+func vmCRD(secretName bool) *unstructured.Unstructured {
+	metadata := map[string]interface{}{
+		"configMapName": map[string]interface{}{"type": "string"},
+		"transport":     map[string]interface{}{"type": "string"},
+	}
+	if secretName {
+		metadata["secretName"] = map[string]interface{}{"type": "string"}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": vmCRDName},
+		"spec": map[string]interface{}{
+			"versions": []interface{}{
+				map[string]interface{}{
+					"name": "v1alpha1",
+					"schema": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"properties": map[string]interface{}{
+								"spec": map[string]interface{}{
+									"properties": map[string]interface{}{
+										"vmMetadata": map[string]interface{}{"properties": metadata},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func Test_resolveBootstrap(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		crd       *unstructured.Unstructured
+		bootstrap string
+		secrets   []string
+		want      string
+		wantErr   bool
+	}{
+		{name: "auto-secret", crd: vmCRD(true), bootstrap: bootstrapAuto, want: bootstrapSecret},
+		{name: "auto-configmap", crd: vmCRD(false), bootstrap: bootstrapAuto, want: bootstrapConfigMap},
+		{name: "auto-crd-not-readable", bootstrap: bootstrapAuto, want: bootstrapConfigMap},
+		{name: "configmap", crd: vmCRD(true), bootstrap: bootstrapConfigMap, want: bootstrapConfigMap},
+		{name: "secret-unsupported", crd: vmCRD(false), bootstrap: bootstrapSecret, wantErr: true},
+		{name: "secret-values-unsupported", crd: vmCRD(false), bootstrap: bootstrapAuto, secrets: []string{"token=abc"}, wantErr: true},
+		{name: "secret-values-configmap", crd: vmCRD(true), bootstrap: bootstrapConfigMap, secrets: []string{"token=abc"}, wantErr: true},
+		{name: "secret-values", crd: vmCRD(true), bootstrap: bootstrapAuto, secrets: []string{"token=abc"}, want: bootstrapSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if tt.crd != nil {
+				objects = append(objects, tt.crd)
+			}
+			setDynamicClient(t, fake.NewSimpleDynamicClient(runtime.NewScheme(), objects...))
+			setOptions(t, &VMOptions{Bootstrap: tt.bootstrap, setSecrets: tt.secrets})
+			secrets, err := loadSecrets()
+			if err != nil {
+				t.Fatal(err)
+			}
+			options.Secrets = secrets
+
+			err = resolveBootstrap(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveBootstrap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && options.Bootstrap != tt.want {
+				t.Errorf("resolveBootstrap() got %s, want %s", options.Bootstrap, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkSecretKeys(t *testing.T) {
+	setOptions(t, &VMOptions{Secrets: map[string]interface{}{"token": "abc"}})
+	if err := checkSecretKeys([]string{"token"}); err != nil {
+		t.Errorf("checkSecretKeys() error = %v", err)
+	}
+	if err := checkSecretKeys([]string{"token", "password"}); err == nil {
+		t.Errorf("checkSecretKeys() want error for missing password")
+	}
+}
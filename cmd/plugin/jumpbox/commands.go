@@ -53,7 +53,17 @@ func CreateJumpBox(ctx context.Context) error {
 			return err
 		}
 	}
-	err = createConfigMap(ctx)
+	for _, volume := range options.Volumes {
+		err = createVolumePVC(ctx, volume)
+		if err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				fmt.Printf("Skip Creating PVC. %s\n", err)
+			} else {
+				return err
+			}
+		}
+	}
+	err = createBootstrap(ctx)
 	if err != nil {
 		if apierrors.IsAlreadyExists(err) {
 			// todo implement replace CM
@@ -79,11 +89,16 @@ func CreateJumpBox(ctx context.Context) error {
 		}
 	}
 
-	return waitCreate(ctx)
+	err = waitCreate(ctx)
+	if err != nil {
+		return err
+	}
+	return postBoot(ctx)
 }
 
-func createSvc(ctx context.Context) error {
-	svc := v1alpha1.VirtualMachineService{
+// newSvc builds the jumpbox VirtualMachineService
+func newSvc() *v1alpha1.VirtualMachineService {
+	return &v1alpha1.VirtualMachineService{
 		TypeMeta: v1.TypeMeta{
 			Kind:       "VirtualMachineService",
 			APIVersion: "vmoperator.vmware.com/v1alpha1",
@@ -97,36 +112,20 @@ func createSvc(ctx context.Context) error {
 			},
 		},
 		Spec: v1alpha1.VirtualMachineServiceSpec{
-			Type: "LoadBalancer",
-			Ports: []v1alpha1.VirtualMachineServicePort{{
-				Name:       "ssh",
-				Protocol:   "TCP",
-				Port:       22,
-				TargetPort: 22,
-			}},
+			Type:  "LoadBalancer",
+			Ports: servicePorts(),
 			Selector: map[string]string{
 				"jumpbox": options.Name,
 			},
 		},
 	}
+}
 
-	data, err := json.Marshal(svc) // Convert to a json string
-	if err != nil {
-		return errors.Wrap(err, "err json marshal")
-	}
-
-	var svcMap map[string]interface{}
-	err = json.Unmarshal(data, &svcMap) // Convert to a map
-	if err != nil {
-		return errors.Wrap(err, "err unmarshal to map")
-	}
-
-	svcData, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&svcMap)
+func createSvc(ctx context.Context) error {
+	dataUnstructured, err := toUnstructured(newSvc())
 	if err != nil {
-		return errors.WithMessage(err, "err converting to unstructured")
+		return err
 	}
-
-	dataUnstructured := &unstructured.Unstructured{Object: svcData}
 	_, err = dynamicClient.Resource(gvrSvc).Namespace(options.Namespace).Create(ctx, dataUnstructured, v1.CreateOptions{})
 	if err != nil {
 		return errors.Wrap(err, "err creating service")
@@ -136,8 +135,13 @@ func createSvc(ctx context.Context) error {
 	return nil
 }
 
-func createSSHSecret(ctx context.Context) error {
-	secret := corev1.Secret{
+// newSSHSecret builds the Secret with the jumpbox ssh keys
+func newSSHSecret() *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: v1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
 		ObjectMeta: v1.ObjectMeta{
 			Name:      options.sshSecretName,
 			Namespace: options.Namespace,
@@ -148,7 +152,10 @@ func createSSHSecret(ctx context.Context) error {
 		},
 		Type: "kubernetes.io/ssh-auth",
 	}
-	_, err := c.CoreV1().Secrets(options.Namespace).Create(ctx, &secret, v1.CreateOptions{})
+}
+
+func createSSHSecret(ctx context.Context) error {
+	_, err := c.CoreV1().Secrets(options.Namespace).Create(ctx, newSSHSecret(), v1.CreateOptions{})
 	if err != nil {
 		return errors.Wrap(err, "err creating secret")
 	}
@@ -158,55 +165,94 @@ func createSSHSecret(ctx context.Context) error {
 }
 
 func createPVC(ctx context.Context) error {
+	return createClaim(ctx, options.pvcName, options.Disk.Size, options.StorageClassName)
+}
+
+// createVolumePVC creates the PVC of an extra volume
+func createVolumePVC(ctx context.Context, volume VolumeSettings) error {
+	return createClaim(ctx, volumePVCName(volume.Name), volume.Size, volume.StorageClass)
+}
+
+// newClaim builds a PVC of the jumpbox
+func newClaim(name string, diskSize string, storageClassName string) (*corev1.PersistentVolumeClaim, error) {
 	filesystem := corev1.PersistentVolumeFilesystem
+	size, err := parseDiskSize(diskSize)
+	if err != nil {
+		return nil, err
+	}
 
-	pvc := corev1.PersistentVolumeClaim{
+	return &corev1.PersistentVolumeClaim{
+		TypeMeta: v1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		},
 		ObjectMeta: v1.ObjectMeta{
-			Name:      options.pvcName,
+			Name:      name,
 			Namespace: options.Namespace,
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
 			Resources: corev1.ResourceRequirements{
 				Requests: map[corev1.ResourceName]resource.Quantity{
-					corev1.ResourceStorage: resource.MustParse("128Gi"),
+					corev1.ResourceStorage: size,
 				},
 			},
-			StorageClassName: &options.StorageClassName,
+			StorageClassName: &storageClassName,
 			VolumeMode:       &filesystem,
 		},
+	}, nil
+}
+
+func createClaim(ctx context.Context, name string, diskSize string, storageClassName string) error {
+	pvc, err := newClaim(name, diskSize, storageClassName)
+	if err != nil {
+		return err
 	}
 
-	_, err := c.CoreV1().PersistentVolumeClaims(options.Namespace).Create(ctx, &pvc, v1.CreateOptions{})
+	_, err = c.CoreV1().PersistentVolumeClaims(options.Namespace).Create(ctx, pvc, v1.CreateOptions{})
 	if err != nil {
 		return errors.Wrap(err, "err creating pvc")
 	}
 
-	fmt.Printf("Created Persisten Volume %s\n", options.pvcName)
+	fmt.Printf("Created Persisten Volume %s\n", name)
 	return nil
 }
 
-func createConfigMap(ctx context.Context) error {
-	cm := corev1.ConfigMap{
+// newConfigMap builds the ConfigMap with the VM metadata
+func newConfigMap() (*corev1.ConfigMap, error) {
+	data, err := metadataData()
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		TypeMeta: v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
 		ObjectMeta: v1.ObjectMeta{
 			Name:      options.configName,
 			Namespace: options.Namespace,
 		},
-		Data: map[string]string{
-			"user-data": options.UserData,
-			"hostname":  options.Name,
-		},
+		Data: data,
+	}, nil
+}
+
+func createConfigMap(ctx context.Context) error {
+	cm, err := newConfigMap()
+	if err != nil {
+		return err
 	}
 
-	_, err := c.CoreV1().ConfigMaps(options.Namespace).Create(ctx, &cm, v1.CreateOptions{})
+	_, err = c.CoreV1().ConfigMaps(options.Namespace).Create(ctx, cm, v1.CreateOptions{})
 	if err != nil {
 		return errors.Wrap(err, "err creating cm")
 	}
 	return nil
 }
 
-func createVM(ctx context.Context) error {
-	vm := v1alpha1.VirtualMachine{
+// newVM builds the jumpbox VirtualMachine
+func newVM() *v1alpha1.VirtualMachine {
+	vm := &v1alpha1.VirtualMachine{
 		TypeMeta: v1.TypeMeta{
 			Kind:       "VirtualMachine",
 			APIVersion: "vmoperator.vmware.com/v1alpha1",
@@ -218,23 +264,19 @@ func createVM(ctx context.Context) error {
 				"jumpbox": options.Name,
 				"vmImage": options.ImageName,
 			},
+			Annotations: map[string]string{
+				annotationSpec: newJumpboxSpec().String(),
+			},
 		},
 		Spec: v1alpha1.VirtualMachineSpec{
-			ImageName:  options.ImageName,
-			ClassName:  options.ClassName,
-			PowerState: "poweredOn",
-			VmMetadata: &v1alpha1.VirtualMachineMetadata{
-				ConfigMapName: options.configName,
-				Transport:     "OvfEnv",
-			},
-			StorageClass: options.StorageClassName,
-			NetworkInterfaces: []v1alpha1.VirtualMachineNetworkInterface{{
-				NetworkName: options.NetworkName,
-				NetworkType: options.NetworkType,
-			},
-			},
+			ImageName:         options.ImageName,
+			ClassName:         options.ClassName,
+			PowerState:        "poweredOn",
+			VmMetadata:        vmMetadata(),
+			StorageClass:      options.StorageClassName,
+			NetworkInterfaces: vmNetworkInterfaces(),
 			Volumes: []v1alpha1.VirtualMachineVolume{{
-				Name: "workspace",
+				Name: workspaceVolumeName,
 				PersistentVolumeClaim: &v1alpha1.PersistentVolumeClaimVolumeSource{
 					PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{
 						ClaimName: options.pvcName,
@@ -244,24 +286,44 @@ func createVM(ctx context.Context) error {
 			}},
 		},
 	}
+	for _, volume := range options.Volumes {
+		vm.Spec.Volumes = append(vm.Spec.Volumes, v1alpha1.VirtualMachineVolume{
+			Name: volume.Name,
+			PersistentVolumeClaim: &v1alpha1.PersistentVolumeClaimVolumeSource{
+				PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: volumePVCName(volume.Name),
+				},
+			},
+		})
+	}
+	return vm
+}
 
-	data, err := json.Marshal(vm) // Convert to a json string
+// toUnstructured converts an object to the unstructured form of the dynamic client
+func toUnstructured(obj interface{}) (*unstructured.Unstructured, error) {
+	data, err := json.Marshal(obj) // Convert to a json string
 	if err != nil {
-		return errors.Wrap(err, "err json marshal")
+		return nil, errors.Wrap(err, "err json marshal")
 	}
 
-	var vmMap map[string]interface{}
-	err = json.Unmarshal(data, &vmMap) // Convert to a map
+	var objMap map[string]interface{}
+	err = json.Unmarshal(data, &objMap) // Convert to a map
 	if err != nil {
-		return errors.Wrap(err, "err unmarshal to map")
+		return nil, errors.Wrap(err, "err unmarshal to map")
 	}
 
-	vmData, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&vmMap)
+	objData, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&objMap)
 	if err != nil {
-		return errors.WithMessage(err, "err converting to unstructured")
+		return nil, errors.WithMessage(err, "err converting to unstructured")
 	}
+	return &unstructured.Unstructured{Object: objData}, nil
+}
 
-	dataUnstructured := &unstructured.Unstructured{Object: vmData}
+func createVM(ctx context.Context) error {
+	dataUnstructured, err := toUnstructured(newVM())
+	if err != nil {
+		return err
+	}
 	_, err = dynamicClient.Resource(gvrVM).Namespace(options.Namespace).Create(ctx, dataUnstructured, v1.CreateOptions{})
 	if err != nil {
 		return errors.Wrap(err, "error creating vm")
@@ -271,11 +333,23 @@ func createVM(ctx context.Context) error {
 	return nil
 }
 
+// vmReadyTimeout is how long to wait for the VM ip and the load balancer ip after creating the jumpbox
+const vmReadyTimeout = 15 * time.Minute
+
 func waitCreate(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, vmReadyTimeout)
+	defer cancel()
+
 	fmt.Print("\nwaiting for VM to be ready ")
-	for true {
+	for {
 		fmt.Print(".")
-		time.Sleep(5 * time.Second)
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return errors.Wrapf(ctx.Err(), "jumpbox %s is not ready, check the VM status with tanzu jumpbox describe %s -n %s",
+				options.Name, options.Name, options.Namespace)
+		case <-time.After(5 * time.Second):
+		}
 
 		vm, err := dynamicClient.Resource(gvrVM).Namespace(options.Namespace).Get(ctx, options.Name, v1.GetOptions{})
 		if err != nil {
@@ -287,19 +361,27 @@ func waitCreate(ctx context.Context) error {
 				if err != nil {
 					return err
 				}
+				ip, err := loadBalancerIP(svc)
+				if err != nil {
+					continue
+				}
 				fmt.Printf("Jumpbox %s is ready\n", vm.Object["metadata"].(map[string]interface{})["name"])
-				fmt.Printf("Load balancer IP: %s\n", svc.Status.LoadBalancer.Ingress[0].IP)
+				fmt.Printf("Load balancer IP: %s\n", ip)
 				fmt.Printf("\nAccess Jumpbox: \ntanzu jumpbox ssh %s -n %s\n", vm.Object["metadata"].(map[string]interface{})["name"], options.Namespace)
-				break
+				return nil
 			}
 		}
 	}
-
-	return nil
 }
 
+// Destroy deletes the jumpbox VM and its resources. The PVCs of all the VM volumes are deleted unless keepVolumes is set
 func Destroy(ctx context.Context) error {
-	err := dynamicClient.Resource(gvrVM).Namespace(options.Namespace).Delete(ctx, options.Name, v1.DeleteOptions{})
+	claims := []string{options.pvcName}
+	vm, err := getVM(ctx)
+	if err == nil {
+		claims = vmClaimNames(vm)
+	}
+	err = dynamicClient.Resource(gvrVM).Namespace(options.Namespace).Delete(ctx, options.Name, v1.DeleteOptions{})
 	if err != nil {
 		return errors.Wrap(err, "error deleting VM")
 	}
@@ -308,26 +390,46 @@ func Destroy(ctx context.Context) error {
 		return errors.Wrap(err, "error deleting VMService")
 	}
 	fmt.Println("VM Service deleted")
-	err = c.CoreV1().ConfigMaps(options.Namespace).Delete(ctx, options.configName, v1.DeleteOptions{})
-	if err != nil {
-		return errors.Wrap(err, "error deleting Config")
-	}
-	fmt.Println("VM Config deleted")
-	err = c.CoreV1().PersistentVolumeClaims(options.Namespace).Delete(ctx, options.pvcName, v1.DeleteOptions{})
+	err = deleteBootstrap(ctx)
 	if err != nil {
-		return errors.Wrap(err, "error deleting PVC")
+		return err
+	}
+	if options.keepVolumes {
+		fmt.Printf("VM Persistent Volumes kept: %s\n", strings.Join(claims, ", "))
+	} else {
+		for _, claim := range claims {
+			err = c.CoreV1().PersistentVolumeClaims(options.Namespace).Delete(ctx, claim, v1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return errors.Wrap(err, "error deleting PVC")
+			}
+			fmt.Printf("VM Persistent Volume %s deleted\n", claim)
+		}
 	}
-	fmt.Println("VM Persistent Volume deleted")
 	err = c.CoreV1().Secrets(options.Namespace).Delete(ctx, options.sshSecretName, v1.DeleteOptions{})
 	if err != nil {
 		return errors.Wrap(err, "error deleting SSH secret")
 	}
 	fmt.Println("VM SSH secret deleted")
+	err = c.CoreV1().ConfigMaps(options.Namespace).Delete(ctx, options.accessConfigName, v1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "error deleting access roster")
+	}
 
 	return nil
 
 }
 
+// vmClaimNames are the PVCs of the VM volumes
+func vmClaimNames(vm *v1alpha1.VirtualMachine) []string {
+	var claims []string
+	for _, volume := range vm.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims = append(claims, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return claims
+}
+
 func PowerOn(ctx context.Context) error {
 	patch := []interface{}{
 		map[string]interface{}{
@@ -374,13 +476,13 @@ func SSH(ctx context.Context) error {
 		return errors.Wrap(err, "error getting svc")
 	}
 
-	if options.User == "" {
-		vmImage := svc.Labels["vmImage"]
-		if strings.Contains(vmImage, "centos") {
-			options.User = "cloud-user"
-		} else {
-			options.User = "ubuntu"
-		}
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return err
+	}
+	options.User, err = loginUser(ctx, spec)
+	if err != nil {
+		return err
 	}
 
 	if options.sshPrivateKeyPath == "" {
@@ -391,7 +493,10 @@ func SSH(ctx context.Context) error {
 		options.sshPrivateKeyPath = keyPath
 	}
 
-	ip := svc.Status.LoadBalancer.Ingress[0].IP
+	ip, err := loadBalancerIP(svc)
+	if err != nil {
+		return err
+	}
 
 	cmd := exec.Command("ssh", "-i", options.sshPrivateKeyPath, options.User+"@"+ip)
 	cmd.Stdout = os.Stdout
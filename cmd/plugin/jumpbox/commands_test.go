@@ -355,3 +355,14 @@ func Test_waitCreate(t *testing.T) {
 		})
 	}
 }
+
+func Test_waitCreateCanceled(t *testing.T) {
+	setOptions(t, &VMOptions{Name: "jb", Namespace: "test"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := waitCreate(ctx)
+	if err == nil || !strings.Contains(err.Error(), "jumpbox jb is not ready") {
+		t.Errorf("waitCreate() error = %v, want not ready", err)
+	}
+}
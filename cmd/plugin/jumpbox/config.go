@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// PluginConfig is the jumpbox plugin config file, stored in ~/.tanzu/jumpbox/config.yaml
+type PluginConfig struct {
+	// CloudUsers overrides the default user of images, checked before the built-in table
+	CloudUsers []CloudUserRule `json:"cloudUsers,omitempty"`
+	// Network are the default network settings of new jumpboxes
+	Network NetworkSettings `json:"network,omitempty"`
+	// Defaults are the create settings used when neither the flags nor the profile set them
+	Defaults Profile `json:"defaults,omitempty"`
+	// Profiles are the named sets of create settings
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	// CurrentProfile is the profile create uses without --profile
+	CurrentProfile string `json:"currentProfile,omitempty"`
+}
+
+func configPath() string {
+	return filepath.Join(options.tanzuDir, "config.yaml")
+}
+
+// loadConfig reads the plugin config file. A missing file is an empty config
+func loadConfig() (*PluginConfig, error) {
+	config := &PluginConfig{}
+	data, err := os.ReadFile(configPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, errors.Wrap(err, "error reading config file")
+	}
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing config file")
+	}
+	return config, nil
+}
+
+// loadConfigOrEmpty reads the plugin config file for the commands that only read defaults from it. An invalid file is
+// reported on stderr, which keeps the command output valid, and ignored
+func loadConfigOrEmpty() *PluginConfig {
+	config, err := loadConfig()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Ignoring config file. %s\n", err)
+		return &PluginConfig{}
+	}
+	return config
+}
+
+func saveConfig(config *PluginConfig) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling config")
+	}
+	err = os.MkdirAll(options.tanzuDir, 0700)
+	if err != nil {
+		return errors.Wrap(err, "error creating config dir")
+	}
+	err = os.WriteFile(configPath(), data, 0600)
+	if err != nil {
+		return errors.Wrap(err, "error writing config file")
+	}
+	return nil
+}
+
+// configValues is the config as nested maps, keyed by the config file field names
+func configValues(config *PluginConfig) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling config")
+	}
+	values := map[string]interface{}{}
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading config values")
+	}
+	return values, nil
+}
+
+// getConfigValue returns the value of a dotted config key, e.g. defaults.namespace
+func getConfigValue(config *PluginConfig, key string) (interface{}, error) {
+	values, err := configValues(config)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{} = values
+	for _, part := range strings.Split(key, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("config key %q is not set", key)
+		}
+		value, ok = m[part]
+		if !ok {
+			return nil, errors.Errorf("config key %q is not set", key)
+		}
+	}
+	return value, nil
+}
+
+// setConfigValue returns the config with a dotted config key set. The value is parsed as YAML, so lists and numbers
+// can be set, and an empty value unsets the key
+func setConfigValue(config *PluginConfig, key string, value string) (*PluginConfig, error) {
+	var parsed interface{}
+	err := yaml.Unmarshal([]byte(value), &parsed)
+	if err != nil {
+		parsed = value
+	}
+	updated, err := setConfigKey(config, key, parsed)
+	if err != nil {
+		if _, ok := parsed.(string); ok || parsed == nil {
+			return nil, err
+		}
+		// a value like 20.04 is a number in YAML, try it as a string for string keys
+		updated, err = setConfigKey(config, key, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+func setConfigKey(config *PluginConfig, key string, value interface{}) (*PluginConfig, error) {
+	values, err := configValues(config)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(key, ".")
+	m := values
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[part] = next
+		}
+		m = next
+	}
+	if value == nil {
+		delete(m, parts[len(parts)-1])
+	} else {
+		m[parts[len(parts)-1]] = value
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling config")
+	}
+	updated := &PluginConfig{}
+	err = yaml.UnmarshalStrict(data, updated)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config key %q or value", key)
+	}
+	return updated, nil
+}
+
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the plugin config file",
+		Long: "Manage the plugin config file, ~/.tanzu/jumpbox/config.yaml. Keys are the dotted field names of the " +
+			"file, e.g. defaults.namespace or network.httpProxy",
+	}
+	configCmd.AddCommand(
+		newConfigGetCmd(),
+		newConfigSetCmd(),
+		newConfigViewCmd(),
+	)
+	return configCmd
+}
+
+func newConfigGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get",
+		Short: "Print a config value",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			value, err := getConfigValue(config, args[0])
+			if err != nil {
+				return err
+			}
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				data, err := yaml.Marshal(value)
+				if err != nil {
+					return errors.Wrap(err, "error marshaling config value")
+				}
+				fmt.Print(string(data))
+			default:
+				fmt.Println(value)
+			}
+			return nil
+		}}
+}
+
+func newConfigSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set",
+		Short: "Set a config value. An empty value unsets it",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			config, err = setConfigValue(config, args[0], args[1])
+			if err != nil {
+				return err
+			}
+			return saveConfig(config)
+		}}
+}
+
+func newConfigViewCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "view",
+		Short: "Print the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			data, err := yaml.Marshal(config)
+			if err != nil {
+				return errors.Wrap(err, "error marshaling config")
+			}
+			fmt.Print(string(data))
+			return nil
+		}}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_setConfigValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		want    *PluginConfig
+		wantErr bool
+	}{
+		{
+			name:  "string",
+			key:   "defaults.namespace",
+			value: "dev",
+			want:  &PluginConfig{Defaults: Profile{Namespace: "dev", User: "alice"}},
+		},
+		{
+			name:  "number",
+			key:   "defaults.cpus",
+			value: "4",
+			want:  &PluginConfig{Defaults: Profile{CPUs: 4, User: "alice"}},
+		},
+		{
+			name:  "number-as-string",
+			key:   "defaults.os",
+			value: "20.04",
+			want:  &PluginConfig{Defaults: Profile{OS: "20.04", User: "alice"}},
+		},
+		{
+			name:  "list",
+			key:   "network.dnsServers",
+			value: "[10.0.0.1, 10.0.0.2]",
+			want:  &PluginConfig{Defaults: Profile{User: "alice"}, Network: NetworkSettings{DNSServers: []string{"10.0.0.1", "10.0.0.2"}}},
+		},
+		{
+			name:  "profile",
+			key:   "profiles.team.storageClass",
+			value: "gold",
+			want:  &PluginConfig{Defaults: Profile{User: "alice"}, Profiles: map[string]Profile{"team": {StorageClassName: "gold"}}},
+		},
+		{
+			name:  "unset",
+			key:   "defaults.user",
+			value: "",
+			want:  &PluginConfig{},
+		},
+		{
+			name:    "unknown-key",
+			key:     "defaults.colour",
+			value:   "blue",
+			wantErr: true,
+		},
+		{
+			name:    "invalid-value",
+			key:     "defaults.cpus",
+			value:   "many",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &PluginConfig{Defaults: Profile{User: "alice"}}
+			got, err := setConfigValue(config, tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setConfigValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("setConfigValue() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getConfigValue(t *testing.T) {
+	config := &PluginConfig{Defaults: Profile{Namespace: "dev", CPUs: 2}}
+	tests := []struct {
+		key     string
+		want    interface{}
+		wantErr bool
+	}{
+		{key: "defaults.namespace", want: "dev"},
+		{key: "defaults.cpus", want: float64(2)},
+		{key: "defaults", want: map[string]interface{}{"namespace": "dev", "cpus": float64(2)}},
+		{key: "defaults.image", wantErr: true},
+		{key: "defaults.namespace.name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := getConfigValue(config, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getConfigValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getConfigValue() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_createFlagPrecedence(t *testing.T) {
+	ctx := context.Background()
+	interactive = func() bool { return false }
+	tanzuDir := t.TempDir()
+	setOptions(t, &VMOptions{tanzuDir: tanzuDir})
+	err := saveConfig(&PluginConfig{
+		Defaults: Profile{Namespace: "dev", ImageName: "ubuntu-2004", StorageClassName: "silver", NetworkType: networkTypeNSXT, User: "carol"},
+		Profiles: map[string]Profile{
+			"team":  {ClassName: "best-effort-small", StorageClassName: "gold", User: "alice"},
+			"large": {ClassName: "best-effort-large"},
+		},
+		CurrentProfile: "team",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		flags            []string
+		wantClass        string
+		wantStorageClass string
+		wantUser         string
+	}{
+		{
+			name:             "current-profile",
+			wantClass:        "best-effort-small",
+			wantStorageClass: "gold",
+			wantUser:         "alice",
+		},
+		{
+			name:             "profile-flag",
+			flags:            []string{"--profile", "large"},
+			wantClass:        "best-effort-large",
+			wantStorageClass: "silver",
+			wantUser:         "carol",
+		},
+		{
+			name:             "flags",
+			flags:            []string{"--user", "bob", "--storage-class", "bronze"},
+			wantClass:        "best-effort-small",
+			wantStorageClass: "bronze",
+			wantUser:         "bob",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{tanzuDir: tanzuDir})
+			cmd := newCreateCmd(ctx)
+			if err := cmd.ParseFlags(tt.flags); err != nil {
+				t.Fatal(err)
+			}
+			_, err := completeCreateFlags(ctx, cmd, []string{"jumpbox-1"})
+			if err != nil {
+				t.Fatalf("completeCreateFlags() error = %v", err)
+			}
+			if options.Namespace != "dev" || options.ClassName != tt.wantClass || options.StorageClassName != tt.wantStorageClass || options.User != tt.wantUser {
+				t.Errorf("completeCreateFlags() got %+v", options)
+			}
+		})
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// effects of a resource change when the jumpbox is applied. Changes without effect are applied in place
+const (
+	effectRecreate   = "recreate"
+	effectPowerCycle = "power cycle"
+	effectRebuild    = "applied on rebuild"
+	effectNotApplied = "not applied"
+)
+
+// jumpboxResource is a resource of the jumpbox as the builders of create make it
+type jumpboxResource struct {
+	Kind   string
+	Name   string
+	Object map[string]interface{}
+	// Fields are the paths compared with the live resource. The fields set by the supervisor are left out
+	Fields []string
+}
+
+// fieldDiff is a field that differs between the live resource and the desired one. From is nil for added fields and
+// To is nil for removed ones
+type fieldDiff struct {
+	Path   string
+	From   interface{}
+	To     interface{}
+	Effect string
+}
+
+func newDiffCmd(ctx context.Context) *cobra.Command {
+	var file string
+	diffCmd := &cobra.Command{
+		Use:   "diff [name]",
+		Short: "Show what apply, update or rebuild would change in a Jumpbox",
+		Long: "Compare the live resources of a jumpbox with the ones built from a manifest, with -f, or from its spec and " +
+			"the update and rebuild flags",
+		Args: cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if (file == "") == (len(args) == 0) {
+				return errors.New("either a jumpbox name or -f is required")
+			}
+			if file == "" && options.Namespace == "" {
+				return errors.New("--namespace is required with a jumpbox name")
+			}
+			if len(args) > 0 {
+				setup(args)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var manifest *JumpboxManifest
+			if file != "" {
+				var err error
+				manifest, err = loadManifest(file)
+				if err != nil {
+					return err
+				}
+			}
+			return Diff(ctx, manifest)
+		}}
+	diffCmd.Flags().StringVarP(&file, "filename", "f", "", "Path to the Jumpbox manifest, - for stdin")
+	diffCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	diffCmd.Flags().StringVarP(&options.ClassName, "class", "c", "", "vm class")
+	addUserDataFlags(diffCmd)
+
+	return diffCmd
+}
+
+// Diff prints the field changes of the jumpbox resources, with the secret values redacted, and whether they need a
+// power cycle or recreate the VM. Without a manifest the desired resources are rebuilt from the jumpbox spec
+func Diff(ctx context.Context, manifest *JumpboxManifest) error {
+	if manifest != nil {
+		manifest.applyTo(options)
+		setup([]string{manifest.Metadata.Name})
+		err := resolveIntentOptions(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	live, err := getJumpboxSpec(ctx)
+	if apierrors.IsNotFound(errors.Cause(err)) && manifest != nil {
+		fmt.Printf("Jumpbox %s doesn't exist, apply creates it\n", options.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		live.applyTo(options)
+	}
+
+	var added []AccessUser
+	var removed []string
+	if options.AccessUsers != nil {
+		current, err := getAccessUsers(ctx)
+		if err != nil {
+			return err
+		}
+		added, removed = accessChanges(current, options.AccessUsers)
+	}
+	desired, err := desiredSpec(ctx)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		err = checkSecretKeys(live.SecretKeys)
+		if err != nil {
+			return err
+		}
+	}
+	changes, err := specChanges(live, desired)
+	if err != nil {
+		return err
+	}
+	recreate := recreateNeeded(changes)
+
+	resources, err := desiredResources()
+	if err != nil {
+		return err
+	}
+	diffs := 0
+	powerCycle := false
+	for _, resource := range resources {
+		liveObject, err := getLiveResource(ctx, resource.Kind, resource.Name)
+		if err != nil {
+			return err
+		}
+		if liveObject == nil {
+			fmt.Printf("%s %s (created)\n", resource.Kind, resource.Name)
+			diffs++
+			continue
+		}
+		fields := resourceChanges(liveObject, resource, recreate)
+		if len(fields) == 0 {
+			continue
+		}
+		fmt.Printf("%s %s\n", resource.Kind, resource.Name)
+		for _, field := range fields {
+			printFieldDiff(resource.Kind, field)
+			powerCycle = powerCycle || field.Effect == effectPowerCycle
+		}
+		diffs += len(fields)
+	}
+
+	if len(added) > 0 || len(removed) > 0 {
+		fmt.Println("Access")
+		for _, user := range added {
+			fmt.Printf("  + %s\n", user.Name)
+		}
+		for _, name := range removed {
+			fmt.Printf("  - %s\n", name)
+		}
+		diffs++
+	}
+
+	switch {
+	case recreate:
+		fmt.Println("The VM is recreated, keeping its volumes and ssh keys")
+	case powerCycle:
+		fmt.Println("Power cycle the jumpbox to apply the class")
+	case diffs == 0:
+		fmt.Printf("Jumpbox %s is up to date\n", options.Name)
+	}
+	return nil
+}
+
+// desiredResources builds the VM, service, PVCs and bootstrap resource of the current options
+func desiredResources() ([]jumpboxResource, error) {
+	var resources []jumpboxResource
+	add := func(kind string, name string, obj interface{}, fields ...string) error {
+		values, err := jsonValues(obj)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, jumpboxResource{Kind: kind, Name: name, Object: values, Fields: fields})
+		return nil
+	}
+
+	err := add("VirtualMachine", options.Name, newVM(), "metadata.labels", "spec")
+	if err != nil {
+		return nil, err
+	}
+	err = add("VirtualMachineService", options.svcName, newSvc(), "spec")
+	if err != nil {
+		return nil, err
+	}
+	claims := []struct{ name, size, storageClass string }{{options.pvcName, options.Disk.Size, options.StorageClassName}}
+	for _, volume := range options.Volumes {
+		claims = append(claims, struct{ name, size, storageClass string }{volumePVCName(volume.Name), volume.Size, volume.StorageClass})
+	}
+	for _, claim := range claims {
+		pvc, err := newClaim(claim.name, claim.size, claim.storageClass)
+		if err != nil {
+			return nil, err
+		}
+		err = add("PersistentVolumeClaim", claim.name, pvc, "spec.accessModes", "spec.resources", "spec.storageClassName")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if options.Bootstrap == bootstrapSecret {
+		secret, err := newBootstrapSecret()
+		if err != nil {
+			return nil, err
+		}
+		// the API server stores the string data as data
+		secret.Data = map[string][]byte{}
+		for k, v := range secret.StringData {
+			secret.Data[k] = []byte(v)
+		}
+		secret.StringData = nil
+		err = add("Secret", options.bootstrapSecretName, secret, "data")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cm, err := newConfigMap()
+		if err != nil {
+			return nil, err
+		}
+		err = add("ConfigMap", options.configName, cm, "data")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// getLiveResource returns the values of a jumpbox resource, or nil when it doesn't exist
+func getLiveResource(ctx context.Context, kind string, name string) (map[string]interface{}, error) {
+	var obj interface{}
+	var err error
+	switch kind {
+	case "VirtualMachine":
+		obj, err = dynamicClient.Resource(gvrVM).Namespace(options.Namespace).Get(ctx, name, v1.GetOptions{})
+	case "VirtualMachineService":
+		obj, err = dynamicClient.Resource(gvrSvc).Namespace(options.Namespace).Get(ctx, name, v1.GetOptions{})
+	case "PersistentVolumeClaim":
+		obj, err = c.CoreV1().PersistentVolumeClaims(options.Namespace).Get(ctx, name, v1.GetOptions{})
+	case "ConfigMap":
+		obj, err = c.CoreV1().ConfigMaps(options.Namespace).Get(ctx, name, v1.GetOptions{})
+	case "Secret":
+		obj, err = c.CoreV1().Secrets(options.Namespace).Get(ctx, name, v1.GetOptions{})
+	default:
+		return nil, errors.Errorf("unsupported resource kind %q", kind)
+	}
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting %s %s", kind, name)
+	}
+	return jsonValues(obj)
+}
+
+// resourceChanges compares the fields of the desired resource with the live one
+func resourceChanges(live map[string]interface{}, resource jumpboxResource, recreate bool) []fieldDiff {
+	var diffs []fieldDiff
+	for _, path := range resource.Fields {
+		from, to := fieldValue(live, path), fieldValue(resource.Object, path)
+		// the data keys are owned by the plugin, the supervisor adds labels and spec fields
+		diffs = append(diffs, diffValues(path, from, to, path == "data")...)
+	}
+	for i := range diffs {
+		diffs[i].Effect = fieldEffect(resource.Kind, diffs[i].Path, recreate)
+	}
+	return diffs
+}
+
+func fieldValue(values map[string]interface{}, path string) interface{} {
+	var value interface{} = values
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// diffValues walks the desired value and returns the leaves that differ from the live value. Lists are compared item
+// by item, and map keys only in the live value are reported when removals is set
+func diffValues(path string, from interface{}, to interface{}, removals bool) []fieldDiff {
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	switch toValue := to.(type) {
+	case map[string]interface{}:
+		fromValue, ok := from.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(toValue))
+		for k := range toValue {
+			keys = append(keys, k)
+		}
+		if removals {
+			for k := range fromValue {
+				if _, ok := toValue[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+		var diffs []fieldDiff
+		for _, k := range keys {
+			diffs = append(diffs, diffValues(path+"."+k, fromValue[k], toValue[k], false)...)
+		}
+		return diffs
+	case []interface{}:
+		fromValue, ok := from.([]interface{})
+		if !ok {
+			break
+		}
+		var diffs []fieldDiff
+		for i := 0; i < len(toValue) || i < len(fromValue); i++ {
+			var a, b interface{}
+			if i < len(fromValue) {
+				a = fromValue[i]
+			}
+			if i < len(toValue) {
+				b = toValue[i]
+			}
+			diffs = append(diffs, diffValues(path+"["+strconv.Itoa(i)+"]", a, b, false)...)
+		}
+		return diffs
+	}
+	return []fieldDiff{{Path: path, From: from, To: to}}
+}
+
+// fieldEffect tells how apply changes a field of a jumpbox resource
+func fieldEffect(kind string, path string, recreate bool) string {
+	switch kind {
+	case "VirtualMachine":
+		if path == "spec.className" {
+			return effectPowerCycle
+		}
+		return effectRecreate
+	case "PersistentVolumeClaim":
+		if path == "spec.resources.requests.storage" {
+			return ""
+		}
+		return effectNotApplied
+	case "ConfigMap", "Secret":
+		if recreate {
+			return effectRecreate
+		}
+		return effectRebuild
+	}
+	return ""
+}
+
+func printFieldDiff(kind string, field fieldDiff) {
+	note := ""
+	if field.Effect != "" {
+		note = " (" + field.Effect + ")"
+	}
+	if kind == "Secret" {
+		fmt.Printf("  %s: %s -> %s%s\n", field.Path, redactedValue(field.From), redactedValue(field.To), note)
+		return
+	}
+	from, fromText := textValue(field.From)
+	to, toText := textValue(field.To)
+	if !(fromText || toText) || (!fromText && field.From != nil) || (!toText && field.To != nil) {
+		fmt.Printf("  %s: %s -> %s%s\n", field.Path, changeValue(field.From), changeValue(field.To), note)
+		return
+	}
+	fmt.Printf("  %s:%s\n", field.Path, note)
+	for _, line := range lineDiff(from, to) {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+func redactedValue(value interface{}) string {
+	if value == nil {
+		return "<none>"
+	}
+	return "<redacted>"
+}
+
+// textValue returns multi-line string values, like the user data, decoding base64 values
+func textValue(value interface{}) (string, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return "", false
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(s); err == nil && utf8.Valid(decoded) && strings.Contains(string(decoded), "\n") {
+		s = string(decoded)
+	}
+	return s, strings.Contains(s, "\n")
+}
+
+// lineDiff returns the removed and added lines between two texts, from their longest common subsequence
+func lineDiff(from string, to string) []string {
+	a, b := splitLines(from), splitLines(to)
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	return lines
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
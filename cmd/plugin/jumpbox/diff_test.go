@@ -0,0 +1,115 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_diffValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     interface{}
+		to       interface{}
+		removals bool
+		want     []fieldDiff
+	}{
+		{
+			name: "equal",
+			from: map[string]interface{}{"a": "x"},
+			to:   map[string]interface{}{"a": "x"},
+		},
+		{
+			name: "leaf",
+			from: map[string]interface{}{"a": map[string]interface{}{"b": "x", "c": "y"}},
+			to:   map[string]interface{}{"a": map[string]interface{}{"b": "z", "c": "y"}},
+			want: []fieldDiff{{Path: "spec.a.b", From: "x", To: "z"}},
+		},
+		{
+			name: "supervisor-field",
+			from: map[string]interface{}{"a": "x", "defaulted": true},
+			to:   map[string]interface{}{"a": "x"},
+		},
+		{
+			name:     "removed-key",
+			from:     map[string]interface{}{"a": "x", "b": "y"},
+			to:       map[string]interface{}{"a": "x"},
+			removals: true,
+			want:     []fieldDiff{{Path: "spec.b", From: "y"}},
+		},
+		{
+			name: "list",
+			from: map[string]interface{}{"volumes": []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}}},
+			to:   map[string]interface{}{"volumes": []interface{}{map[string]interface{}{"name": "c"}}},
+			want: []fieldDiff{
+				{Path: "spec.volumes[0].name", From: "a", To: "c"},
+				{Path: "spec.volumes[1]", From: map[string]interface{}{"name": "b"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffValues("spec", tt.from, tt.to, tt.removals); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffValues() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resourceChanges(t *testing.T) {
+	setOptions(t, &VMOptions{
+		Namespace:        "dev",
+		ImageName:        "ubuntu-2004",
+		ClassName:        "best-effort-small",
+		StorageClassName: "gold",
+		NetworkType:      networkTypeNSXT,
+		Transport:        transportCloudInit,
+		Bootstrap:        bootstrapSecret,
+		UserData:         "I2Nsb3VkLWNvbmZpZwp1c2VyczogW10K",
+		Disk:             DiskSettings{Size: "64Gi", FSType: "ext4"},
+		Ports:            []ServicePort{{Name: "http", Port: 80, TargetPort: 80, Protocol: "TCP"}},
+	})
+	setup([]string{"jumpbox-1"})
+	live, err := desiredResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options.ClassName = "best-effort-large"
+	options.Disk.Size = "128Gi"
+	options.Ports = nil
+	options.UserData = "I2Nsb3VkLWNvbmZpZwp1c2VyczogW2FsaWNlXQo="
+	desired, err := desiredResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(desired) != 4 || desired[3].Kind != "Secret" {
+		t.Fatalf("desiredResources() = %+v, want the VM, service, PVC and bootstrap secret", desired)
+	}
+
+	want := [][]fieldDiff{
+		{{Path: "spec.className", From: "best-effort-small", To: "best-effort-large", Effect: effectPowerCycle}},
+		{{Path: "spec.ports[1]", From: map[string]interface{}{"name": "http", "port": float64(80), "targetPort": float64(80), "protocol": "TCP"}}},
+		{{Path: "spec.resources.requests.storage", From: "64Gi", To: "128Gi"}},
+		{{Path: "data.user-data", From: "I2Nsb3VkLWNvbmZpZwp1c2VyczogW10K", To: "I2Nsb3VkLWNvbmZpZwp1c2VyczogW2FsaWNlXQo=", Effect: effectRebuild}},
+	}
+	for i, resource := range desired {
+		// the supervisor sets status and defaults
+		live[i].Object["status"] = map[string]interface{}{"phase": "Bound"}
+		live[i].Object["metadata"].(map[string]interface{})["uid"] = "1234"
+		if got := resourceChanges(live[i].Object, resource, false); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("resourceChanges() of %s = %+v, want %+v", resource.Kind, got, want[i])
+		}
+	}
+}
+
+func Test_lineDiff(t *testing.T) {
+	from := "#cloud-config\nusers:\n- alice\npackages:\n- git\n"
+	to := "#cloud-config\nusers:\n- alice\n- bob\npackages:\n- vim\n"
+	want := []string{"+ - bob", "- - git", "+ - vim"}
+	if got := lineDiff(from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("lineDiff() = %v, want %v", got, want)
+	}
+	if got := lineDiff("", "a\n"); !reflect.DeepEqual(got, []string{"+ a"}) {
+		t.Errorf("lineDiff() = %v, want [+ a]", got)
+	}
+}
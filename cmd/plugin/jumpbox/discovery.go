@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// storageClassQuotaSuffix is the suffix of the namespace ResourceQuota keys limiting the storage of a storage class
+const storageClassQuotaSuffix = ".storageclass.storage.k8s.io/requests.storage"
+
+var (
+	gvrContentSourceBinding = schema.GroupVersionResource{
+		Group:    "vmoperator.vmware.com",
+		Version:  "v1alpha1",
+		Resource: "contentsourcebindings",
+	}
+	gvrContentSource = schema.GroupVersionResource{
+		Group:    "vmoperator.vmware.com",
+		Version:  "v1alpha1",
+		Resource: "contentsources",
+	}
+	gvrVMClassBinding = schema.GroupVersionResource{
+		Group:    "vmoperator.vmware.com",
+		Version:  "v1alpha1",
+		Resource: "virtualmachineclassbindings",
+	}
+	gvrVMClass = schema.GroupVersionResource{
+		Group:    "vmoperator.vmware.com",
+		Version:  "v1alpha1",
+		Resource: "virtualmachineclasses",
+	}
+	// gvrNSXNetwork are the NSX-T virtual networks of the namespace
+	gvrNSXNetwork = schema.GroupVersionResource{
+		Group:    "vmware.com",
+		Version:  "v1alpha1",
+		Resource: "virtualnetworks",
+	}
+	// gvrVDSNetwork are the vSphere distributed networks of the namespace
+	gvrVDSNetwork = schema.GroupVersionResource{
+		Group:    "netoperator.vmware.com",
+		Version:  "v1alpha1",
+		Resource: "networks",
+	}
+)
+
+// ImageInfo is a VM image usable in the namespace
+type ImageInfo struct {
+	Name        string `json:"name"`
+	OS          string `json:"os"`
+	Version     string `json:"version"`
+	DefaultUser string `json:"defaultUser"`
+}
+
+// ClassInfo is a VM class bound to the namespace
+type ClassInfo struct {
+	Name   string `json:"name"`
+	CPUs   int64  `json:"cpus"`
+	Memory string `json:"memory"`
+}
+
+// StorageClassInfo is a storage class assigned to the namespace, with its storage quota
+type StorageClassInfo struct {
+	Name  string `json:"name"`
+	Limit string `json:"limit"`
+	Used  string `json:"used"`
+}
+
+// NetworkInfo is a network of the namespace. Type is the create --network-type
+type NetworkInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func newImagesCmd(ctx context.Context) *cobra.Command {
+	imagesCmd := &cobra.Command{
+		Use:   "images",
+		Short: "List the VM images usable in the namespace",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			images, err := listImages(ctx)
+			if err != nil {
+				return err
+			}
+			return printList(images, "NAME\tOS\tVERSION\tDEFAULT USER", func(w *tabwriter.Writer) {
+				for _, image := range images {
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", image.Name, image.OS, image.Version, image.DefaultUser)
+				}
+			})
+		}}
+	addDiscoveryFlags(imagesCmd)
+	return imagesCmd
+}
+
+func newClassesCmd(ctx context.Context) *cobra.Command {
+	classesCmd := &cobra.Command{
+		Use:   "classes",
+		Short: "List the VM classes bound to the namespace",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			classes, err := listClasses(ctx)
+			if err != nil {
+				return err
+			}
+			return printList(classes, "NAME\tCPUS\tMEMORY", func(w *tabwriter.Writer) {
+				for _, class := range classes {
+					_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n", class.Name, class.CPUs, class.Memory)
+				}
+			})
+		}}
+	addDiscoveryFlags(classesCmd)
+	return classesCmd
+}
+
+func newStorageClassesCmd(ctx context.Context) *cobra.Command {
+	storageClassesCmd := &cobra.Command{
+		Use:   "storage-classes",
+		Short: "List the storage classes assigned to the namespace",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			storageClasses, err := listStorageClasses(ctx)
+			if err != nil {
+				return err
+			}
+			return printList(storageClasses, "NAME\tLIMIT\tUSED", func(w *tabwriter.Writer) {
+				for _, sc := range storageClasses {
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", sc.Name, sc.Limit, sc.Used)
+				}
+			})
+		}}
+	addDiscoveryFlags(storageClassesCmd)
+	return storageClassesCmd
+}
+
+func newNetworksCmd(ctx context.Context) *cobra.Command {
+	networksCmd := &cobra.Command{
+		Use:   "networks",
+		Short: "List the networks of the namespace",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			networks, err := listNetworks(ctx)
+			if err != nil {
+				return err
+			}
+			return printList(networks, "NAME\tTYPE", func(w *tabwriter.Writer) {
+				for _, network := range networks {
+					_, _ = fmt.Fprintf(w, "%s\t%s\n", network.Name, network.Type)
+				}
+			})
+		}}
+	addDiscoveryFlags(networksCmd)
+	return networksCmd
+}
+
+func addDiscoveryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	cmd.Flags().StringVarP(&options.output, "output", "o", outputTable, "Output format. `table` or `json`")
+	_ = cmd.MarkFlagRequired("namespace")
+}
+
+// printList prints items as json or as a table with header and rows
+func printList(items interface{}, header string, rows func(w *tabwriter.Writer)) error {
+	switch options.output {
+	case outputJSON:
+		data, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "err json marshal")
+		}
+		fmt.Println(string(data))
+		return nil
+	case outputTable, "":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		_, _ = fmt.Fprintln(w, header)
+		rows(w)
+		return w.Flush()
+	default:
+		return errors.Errorf("invalid output %q. valid values are %s and %s", options.output, outputTable, outputJSON)
+	}
+}
+
+// listImages lists the images usable in the namespace with their OS and default user
+func listImages(ctx context.Context) ([]ImageInfo, error) {
+	list, err := boundImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config := loadConfigOrEmpty()
+
+	images := make([]ImageInfo, 0, len(list))
+	for i := range list {
+		image := &list[i]
+		osName := image.Spec.OSInfo.Type
+		if image.Spec.ProductInfo.Product != "" {
+			osName = image.Spec.ProductInfo.Product
+		}
+		version := image.Spec.ProductInfo.FullVersion
+		if version == "" {
+			version = image.Spec.OSInfo.Version
+		}
+		images = append(images, ImageInfo{
+			Name:        image.Name,
+			OS:          osName,
+			Version:     version,
+			DefaultUser: imageCloudUser(image, config),
+		})
+	}
+	return images, nil
+}
+
+// boundImages lists the supported images of the content libraries bound to the namespace with ContentSourceBindings,
+// sorted by name. Supervisors without content source bindings list all the images
+func boundImages(ctx context.Context) ([]v1alpha1.VirtualMachineImage, error) {
+	providers, err := boundContentProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := dynamicClient.Resource(gvrVMImage).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing images")
+	}
+
+	var images []v1alpha1.VirtualMachineImage
+	for _, item := range list.Items {
+		image := v1alpha1.VirtualMachineImage{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &image)
+		if err != nil {
+			return nil, errors.WithMessage(err, "err converting image")
+		}
+		if providers != nil && !providers[image.Spec.ProviderRef.Name] {
+			continue
+		}
+		if image.Status.ImageSupported != nil && !*image.Status.ImageSupported {
+			continue
+		}
+		images = append(images, image)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Name < images[j].Name })
+	return images, nil
+}
+
+// boundContentProviders returns the content library providers bound to the namespace, or nil when the supervisor
+// has no content source bindings
+func boundContentProviders(ctx context.Context) (map[string]bool, error) {
+	bindings, err := dynamicClient.Resource(gvrContentSourceBinding).Namespace(options.Namespace).List(ctx, v1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing content source bindings")
+	}
+
+	providers := map[string]bool{}
+	for _, item := range bindings.Items {
+		binding := &v1alpha1.ContentSourceBinding{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, binding)
+		if err != nil {
+			return nil, errors.WithMessage(err, "err converting content source binding")
+		}
+		res, err := dynamicClient.Resource(gvrContentSource).Get(ctx, binding.ContentSourceRef.Name, v1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting content source")
+		}
+		source := &v1alpha1.ContentSource{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, source)
+		if err != nil {
+			return nil, errors.WithMessage(err, "err converting content source")
+		}
+		providers[source.Spec.ProviderRef.Name] = true
+	}
+	return providers, nil
+}
+
+// listClasses lists the VM classes bound to the namespace with VirtualMachineClassBindings
+func listClasses(ctx context.Context) ([]ClassInfo, error) {
+	bindings, err := dynamicClient.Resource(gvrVMClassBinding).Namespace(options.Namespace).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing vm class bindings")
+	}
+
+	classes := []ClassInfo{}
+	for _, item := range bindings.Items {
+		binding := &v1alpha1.VirtualMachineClassBinding{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, binding)
+		if err != nil {
+			return nil, errors.WithMessage(err, "err converting vm class binding")
+		}
+		res, err := dynamicClient.Resource(gvrVMClass).Get(ctx, binding.ClassRef.Name, v1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting vm class")
+		}
+		class := &v1alpha1.VirtualMachineClass{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, class)
+		if err != nil {
+			return nil, errors.WithMessage(err, "err converting vm class")
+		}
+		classes = append(classes, ClassInfo{
+			Name:   class.Name,
+			CPUs:   class.Spec.Hardware.Cpus,
+			Memory: class.Spec.Hardware.Memory.String(),
+		})
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i].Name < classes[j].Name })
+	return classes, nil
+}
+
+// listStorageClasses lists the storage classes with a storage limit in the namespace ResourceQuotas, which is how
+// storage policies are assigned to supervisor namespaces
+func listStorageClasses(ctx context.Context) ([]StorageClassInfo, error) {
+	quotas, err := c.CoreV1().ResourceQuotas(options.Namespace).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing resource quotas")
+	}
+
+	storageClasses := []StorageClassInfo{}
+	for _, quota := range quotas.Items {
+		for key, limit := range quota.Spec.Hard {
+			if !strings.HasSuffix(string(key), storageClassQuotaSuffix) {
+				continue
+			}
+			used := quota.Status.Used[key]
+			storageClasses = append(storageClasses, StorageClassInfo{
+				Name:  strings.TrimSuffix(string(key), storageClassQuotaSuffix),
+				Limit: limit.String(),
+				Used:  used.String(),
+			})
+		}
+	}
+	sort.Slice(storageClasses, func(i, j int) bool { return storageClasses[i].Name < storageClasses[j].Name })
+	return storageClasses, nil
+}
+
+// listNetworks lists the NSX-T and vSphere distributed networks of the namespace. The network API of the networking
+// stack not used by the supervisor is not installed and is skipped
+func listNetworks(ctx context.Context) ([]NetworkInfo, error) {
+	networks := []NetworkInfo{}
+	for _, source := range []struct {
+		gvr         schema.GroupVersionResource
+		networkType string
+	}{
+		{gvr: gvrNSXNetwork, networkType: networkTypeNSXT},
+		{gvr: gvrVDSNetwork, networkType: networkTypeVDS},
+	} {
+		list, err := dynamicClient.Resource(source.gvr).Namespace(options.Namespace).List(ctx, v1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error listing networks")
+		}
+		for _, item := range list.Items {
+			networks = append(networks, NetworkInfo{Name: item.GetName(), Type: source.networkType})
+		}
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
+	return networks, nil
+}
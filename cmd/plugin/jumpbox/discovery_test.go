@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1/install"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	simpleFake "k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"testing"
+)
+
+func Test_listImages(t *testing.T) {
+	ctx := context.Background()
+	unsupported := false
+	image := func(name string, provider string, product string, supported *bool) runtime.Object {
+		return &v1alpha1.VirtualMachineImage{
+			TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineImage", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec: v1alpha1.VirtualMachineImageSpec{
+				ProviderRef: v1alpha1.ContentProviderReference{Kind: "ContentLibraryProvider", Name: provider},
+				ProductInfo: v1alpha1.VirtualMachineImageProductInfo{Product: product, FullVersion: "1.0"},
+			},
+			Status: v1alpha1.VirtualMachineImageStatus{ImageSupported: supported},
+		}
+	}
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	setDynamicClient(t, fake.NewSimpleDynamicClient(scheme,
+		&v1alpha1.ContentSourceBinding{
+			TypeMeta:         v1.TypeMeta{Kind: "ContentSourceBinding", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta:       v1.ObjectMeta{Name: "library", Namespace: "test"},
+			ContentSourceRef: v1alpha1.ContentSourceReference{Kind: "ContentSource", Name: "library-source"},
+		},
+		&v1alpha1.ContentSource{
+			TypeMeta:   v1.TypeMeta{Kind: "ContentSource", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: "library-source"},
+			Spec: v1alpha1.ContentSourceSpec{
+				ProviderRef: v1alpha1.ContentProviderReference{Kind: "ContentLibraryProvider", Name: "library-provider"},
+			},
+		},
+		image("ubuntu-2004", "library-provider", "Ubuntu", nil),
+		image("centos-8", "library-provider", "CentOS", nil),
+		image("old-photon", "library-provider", "Photon", &unsupported),
+		image("other-library", "other-provider", "Ubuntu", nil),
+	))
+	setOptions(t, &VMOptions{Namespace: "test", tanzuDir: t.TempDir()})
+
+	got, err := listImages(ctx)
+	if err != nil {
+		t.Fatalf("listImages() error = %v", err)
+	}
+	want := []ImageInfo{
+		{Name: "centos-8", OS: "CentOS", Version: "1.0", DefaultUser: "cloud-user"},
+		{Name: "ubuntu-2004", OS: "Ubuntu", Version: "1.0", DefaultUser: "ubuntu"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listImages() got %+v, want %+v", got, want)
+	}
+}
+
+func Test_listClasses(t *testing.T) {
+	ctx := context.Background()
+	class := func(name string, cpus int64, memory string) runtime.Object {
+		return &v1alpha1.VirtualMachineClass{
+			TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineClass", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec: v1alpha1.VirtualMachineClassSpec{
+				Hardware: v1alpha1.VirtualMachineClassHardware{Cpus: cpus, Memory: resource.MustParse(memory)},
+			},
+		}
+	}
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	setDynamicClient(t, fake.NewSimpleDynamicClient(scheme,
+		&v1alpha1.VirtualMachineClassBinding{
+			TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineClassBinding", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: "best-effort-small", Namespace: "test"},
+			ClassRef:   v1alpha1.ClassReference{Kind: "VirtualMachineClass", Name: "best-effort-small"},
+		},
+		class("best-effort-small", 2, "4Gi"),
+		class("best-effort-large", 4, "16Gi"),
+	))
+	setOptions(t, &VMOptions{Namespace: "test"})
+
+	got, err := listClasses(ctx)
+	if err != nil {
+		t.Fatalf("listClasses() error = %v", err)
+	}
+	want := []ClassInfo{{Name: "best-effort-small", CPUs: 2, Memory: "4Gi"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listClasses() got %+v, want %+v", got, want)
+	}
+}
+
+func Test_listStorageClasses(t *testing.T) {
+	ctx := context.Background()
+	setClient(t, simpleFake.NewSimpleClientset(&corev1.ResourceQuota{
+		ObjectMeta: v1.ObjectMeta{Name: "test-storagequota", Namespace: "test"},
+		Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{
+			"vsan-default-storage-policy" + storageClassQuotaSuffix: resource.MustParse("500Gi"),
+			corev1.ResourceRequestsStorage:                          resource.MustParse("1Ti"),
+		}},
+		Status: corev1.ResourceQuotaStatus{Used: corev1.ResourceList{
+			"vsan-default-storage-policy" + storageClassQuotaSuffix: resource.MustParse("128Gi"),
+		}},
+	}))
+	setOptions(t, &VMOptions{Namespace: "test"})
+
+	got, err := listStorageClasses(ctx)
+	if err != nil {
+		t.Fatalf("listStorageClasses() error = %v", err)
+	}
+	want := []StorageClassInfo{{Name: "vsan-default-storage-policy", Limit: "500Gi", Used: "128Gi"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listStorageClasses() got %+v, want %+v", got, want)
+	}
+}
+
+func Test_listNetworks(t *testing.T) {
+	ctx := context.Background()
+	network := &unstructured.Unstructured{}
+	network.SetAPIVersion("netoperator.vmware.com/v1alpha1")
+	network.SetKind("Network")
+	network.SetName("workload-network")
+	network.SetNamespace("test")
+	setDynamicClient(t, fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gvrNSXNetwork: "VirtualNetworkList",
+		gvrVDSNetwork: "NetworkList",
+	}, network))
+	setOptions(t, &VMOptions{Namespace: "test"})
+
+	got, err := listNetworks(ctx)
+	if err != nil {
+		t.Fatalf("listNetworks() error = %v", err)
+	}
+	want := []NetworkInfo{{Name: "workload-network", Type: networkTypeVDS}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listNetworks() got %+v, want %+v", got, want)
+	}
+}
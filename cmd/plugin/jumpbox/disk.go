@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+const (
+	defaultDiskSize   = "128Gi"
+	defaultDiskFSType = "ext4"
+)
+
+// mebibyte is the disk size unit. Disks are found by size in MiB before they are formatted, so sizes must be whole MiB
+const mebibyte = 1 << 20
+
+// workspaceLabel is the filesystem label of the workspace disk. Disks formatted by older plugin versions with fs_setup
+// have the same label, so they are found again after a rebuild
+const workspaceLabel = "workspace"
+
+// workspaceVolumeName is the name of the workspace disk in the VM volumes
+const workspaceVolumeName = "workspace"
+
+var diskFSTypes = []string{"ext4", "xfs"}
+
+// DiskSettings are the workspace disk settings, shared by the PVC, the VM volume and the cloud-config mount
+type DiskSettings struct {
+	Size   string `json:"size,omitempty"`
+	FSType string `json:"fsType,omitempty"`
+	// MountPath defaults to the user home
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// VolumeSettings is an extra disk of the jumpbox, with its own PVC
+type VolumeSettings struct {
+	Name         string `json:"name"`
+	Size         string `json:"size"`
+	StorageClass string `json:"storageClass"`
+	MountPath    string `json:"mountPath"`
+	FSType       string `json:"fsType"`
+}
+
+// volumeNameRegex keeps volume names valid as PVC names and as ext4 and xfs filesystem labels
+var volumeNameRegex = regexp.MustCompile(`^[a-z]([a-z0-9-]{0,10}[a-z0-9])?$`)
+
+func addDiskFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&options.Disk.Size, "disk-size", "", defaultDiskSize, "Workspace disk size, a whole number of MiB. Disks are found by size before they are formatted, so it must differ from the --volume sizes")
+	cmd.Flags().StringVarP(&options.Disk.FSType, "fs-type", "", defaultDiskFSType, "Workspace disk filesystem. `ext4` or `xfs`")
+	cmd.Flags().StringVarP(&options.Disk.MountPath, "mount-path", "", "", "Workspace disk mount path. Defaults to the user home")
+	cmd.Flags().StringArrayVarP(&options.volumeFlags, "volume", "", nil, "Extra disk, `name=<n>,size=<s>,storage-class=<sc>,mount=<path>[,fs-type=<fs>]`. storage-class defaults to --storage-class. Can be repeated. Disks are found by size before they are formatted, so the workspace disk and the volumes need different sizes")
+}
+
+// volumePVCName is the PVC of an extra volume
+func volumePVCName(volume string) string {
+	return options.Name + "-" + volume + "-pvc"
+}
+
+// quantity is the disk size as a resource quantity
+func (d DiskSettings) quantity() (resource.Quantity, error) {
+	return parseDiskSize(d.Size)
+}
+
+func parseDiskSize(size string) (resource.Quantity, error) {
+	if size == "" {
+		size = defaultDiskSize
+	}
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		return q, errors.Wrap(err, fmt.Sprintf("invalid disk size %q", size))
+	}
+	if q.Value()%mebibyte != 0 {
+		return q, errors.Errorf("invalid disk size %q, use a whole number of MiB like 512Mi or 100Gi", size)
+	}
+	return q, nil
+}
+
+// resolveDisk validates the disk settings and sets their defaults
+func resolveDisk() error {
+	q, err := options.Disk.quantity()
+	if err != nil {
+		return err
+	}
+	if q.Sign() <= 0 {
+		return errors.Errorf("invalid disk size %q", options.Disk.Size)
+	}
+	options.Disk.Size = q.String()
+
+	if options.Disk.FSType == "" {
+		options.Disk.FSType = defaultDiskFSType
+	}
+	err = validateFSType(options.Disk.FSType)
+	if err != nil {
+		return err
+	}
+
+	if options.Disk.MountPath == "" {
+		options.Disk.MountPath = path.Join("/home", options.User)
+	}
+	options.Disk.MountPath, err = cleanMountPath(options.Disk.MountPath)
+	return err
+}
+
+func validateFSType(fsType string) error {
+	for _, t := range diskFSTypes {
+		if fsType == t {
+			return nil
+		}
+	}
+	return errors.Errorf("invalid filesystem %q. valid values are %s", fsType, strings.Join(diskFSTypes, ", "))
+}
+
+func cleanMountPath(mountPath string) (string, error) {
+	if !path.IsAbs(mountPath) || path.Clean(mountPath) == "/" {
+		return "", errors.Errorf("invalid mount path %q", mountPath)
+	}
+	return path.Clean(mountPath), nil
+}
+
+// parseVolume parses a --volume flag
+func parseVolume(flag string) (VolumeSettings, error) {
+	volume := VolumeSettings{StorageClass: options.StorageClassName, FSType: defaultDiskFSType}
+	for _, field := range strings.Split(flag, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return volume, errors.Errorf("invalid volume %q, expected key=value fields", flag)
+		}
+		switch kv[0] {
+		case "name":
+			volume.Name = kv[1]
+		case "size":
+			volume.Size = kv[1]
+		case "storage-class":
+			volume.StorageClass = kv[1]
+		case "mount":
+			volume.MountPath = kv[1]
+		case "fs-type":
+			volume.FSType = kv[1]
+		default:
+			return volume, errors.Errorf("invalid volume %q, unknown field %q", flag, kv[0])
+		}
+	}
+	if volume.Size == "" || volume.MountPath == "" {
+		return volume, errors.Errorf("invalid volume %q, size and mount are required", flag)
+	}
+	return volume, nil
+}
+
+// resolveVolumes adds the --volume flags to the volumes and validates them
+func resolveVolumes() error {
+	for _, flag := range options.volumeFlags {
+		volume, err := parseVolume(flag)
+		if err != nil {
+			return err
+		}
+		options.Volumes = append(options.Volumes, volume)
+	}
+	options.volumeFlags = nil
+
+	mounts := map[string]string{options.Disk.MountPath: workspaceVolumeName}
+	names := map[string]bool{workspaceVolumeName: true}
+	for i, volume := range options.Volumes {
+		if !volumeNameRegex.MatchString(volume.Name) {
+			return errors.Errorf("invalid volume name %q. use up to 12 lowercase letters, digits and dashes", volume.Name)
+		}
+		if names[volume.Name] {
+			return errors.Errorf("duplicated volume %q", volume.Name)
+		}
+		names[volume.Name] = true
+
+		q, err := parseDiskSize(volume.Size)
+		if err != nil {
+			return err
+		}
+		options.Volumes[i].Size = q.String()
+
+		err = validateFSType(volume.FSType)
+		if err != nil {
+			return err
+		}
+		options.Volumes[i].MountPath, err = cleanMountPath(volume.MountPath)
+		if err != nil {
+			return err
+		}
+		if other, ok := mounts[options.Volumes[i].MountPath]; ok {
+			return errors.Errorf("volume %s has the same mount path as %s", volume.Name, other)
+		}
+		mounts[options.Volumes[i].MountPath] = volume.Name
+		if volume.StorageClass == "" {
+			return errors.Errorf("volume %s has no storage class", volume.Name)
+		}
+	}
+	return nil
+}
+
+// jumpboxDisks are the workspace disk, named by its label, and the extra volumes
+func jumpboxDisks() []VolumeSettings {
+	disks := []VolumeSettings{{
+		Name:      workspaceLabel,
+		Size:      options.Disk.Size,
+		MountPath: options.Disk.MountPath,
+		FSType:    options.Disk.FSType,
+	}}
+	return append(disks, options.Volumes...)
+}
+
+// diskPVCName is the PVC of a disk of jumpboxDisks
+func diskPVCName(disk VolumeSettings) string {
+	if disk.Name == workspaceLabel {
+		return options.pvcName
+	}
+	return volumePVCName(disk.Name)
+}
+
+// newDisks returns the disks without a PVC yet. They are formatted on their first boot
+func newDisks(ctx context.Context) ([]VolumeSettings, error) {
+	var disks []VolumeSettings
+	for _, disk := range jumpboxDisks() {
+		_, err := c.CoreV1().PersistentVolumeClaims(options.Namespace).Get(ctx, diskPVCName(disk), v1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			disks = append(disks, disk)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting pvc")
+		}
+	}
+	return disks, nil
+}
+
+// checkNewDiskSizes validates that the disks formatted on first boot have different sizes. They have no label yet and
+// are found by size, see diskMountScript. Disks already formatted are found by label and can have any size
+func checkNewDiskSizes(disks []VolumeSettings) error {
+	sizes := map[int64]string{}
+	for _, disk := range disks {
+		q, err := parseDiskSize(disk.Size)
+		if err != nil {
+			return err
+		}
+		if other, ok := sizes[q.Value()]; ok {
+			return errors.Errorf("volume %s has the same size as %s. new disks are found by size on first boot, use different sizes", disk.Name, other)
+		}
+		sizes[q.Value()] = disk.Name
+	}
+	return nil
+}
+
+// diskMountScript finds a disk by its label or, when not formatted yet, as the unpartitioned disk without a filesystem
+// of its PVC size in MiB, formats it and mounts it by label. It runs on every boot and is a no-op once mounted
+const diskMountScript = `set -eu
+label=%[1]s fstype=%[2]s mount_path=%[3]s size_mib=%[4]d
+mountpoint -q "$mount_path" && exit 0
+dev=$(blkid -L "$label" || true)
+if [ -z "$dev" ]; then
+  for disk in $(lsblk -dnpo NAME,TYPE | awk '$2 == "disk" { print $1 }'); do
+    [ "$(( $(blockdev --getsize64 "$disk") / 1048576 ))" = "$size_mib" ] || continue
+    [ "$(lsblk -npo NAME "$disk" | wc -l)" -eq 1 ] || continue
+    [ -z "$(blkid -o value -s TYPE "$disk" || true)" ] || continue
+    dev=$disk
+    break
+  done
+  if [ -z "$dev" ]; then
+    echo "$label disk of ${size_mib}MiB not found" >&2
+    exit 1
+  fi
+  mkfs -t "$fstype" -L "$label" "$dev"
+fi
+mkdir -p "$mount_path"
+grep -q "^LABEL=$label " /etc/fstab || echo "LABEL=$label $mount_path $fstype defaults,nofail 0 2" >> /etc/fstab
+mount "$mount_path"
+`
+
+// disksCloudConfig is the cloud-config, merged over the template, that mounts the workspace disk and the extra
+// volumes before the users are created, so the user home can live in them, and gives the user their mount paths
+func disksCloudConfig() ([]byte, error) {
+	var bootcmd, runcmd []interface{}
+	for _, disk := range jumpboxDisks() {
+		q, err := parseDiskSize(disk.Size)
+		if err != nil {
+			return nil, err
+		}
+		script := fmt.Sprintf(diskMountScript, shellQuote(disk.Name), shellQuote(disk.FSType), shellQuote(disk.MountPath), q.Value()/mebibyte)
+		bootcmd = append(bootcmd, []interface{}{"sh", "-c", script})
+		runcmd = append(runcmd, []interface{}{"chown", options.User + ":", disk.MountPath})
+	}
+	data, err := yaml.Marshal(map[string]interface{}{
+		"bootcmd": bootcmd,
+		"runcmd":  runcmd,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling disks cloud-config")
+	}
+	return append([]byte(cloudConfigHeader+"\n"), data...), nil
+}
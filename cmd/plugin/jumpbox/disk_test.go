@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	simpleFake "k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_resolveDisk(t *testing.T) {
+	tests := []struct {
+		name    string
+		disk    DiskSettings
+		want    DiskSettings
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: DiskSettings{Size: "128Gi", FSType: "ext4", MountPath: "/home/alice"},
+		},
+		{
+			name: "custom",
+			disk: DiskSettings{Size: "50Gi", FSType: "xfs", MountPath: "/data/"},
+			want: DiskSettings{Size: "50Gi", FSType: "xfs", MountPath: "/data"},
+		},
+		{
+			name:    "invalid-size",
+			disk:    DiskSettings{Size: "lots"},
+			wantErr: true,
+		},
+		{
+			name: "fractional-size",
+			disk: DiskSettings{Size: "1.5Gi", FSType: "ext4", MountPath: "/data"},
+			want: DiskSettings{Size: "1536Mi", FSType: "ext4", MountPath: "/data"},
+		},
+		{
+			name:    "size-not-mib-aligned",
+			disk:    DiskSettings{Size: "100G"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-fs-type",
+			disk:    DiskSettings{FSType: "ntfs"},
+			wantErr: true,
+		},
+		{
+			name:    "relative-mount-path",
+			disk:    DiskSettings{MountPath: "data"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{User: "alice", Disk: tt.disk})
+			err := resolveDisk()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveDisk() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && options.Disk != tt.want {
+				t.Errorf("resolveDisk() got %+v, want %+v", options.Disk, tt.want)
+			}
+		})
+	}
+}
+
+func Test_disksCloudConfig(t *testing.T) {
+	setOptions(t, &VMOptions{
+		User:    "alice",
+		Disk:    DiskSettings{Size: "1Gi", FSType: "xfs", MountPath: "/data"},
+		Volumes: []VolumeSettings{{Name: "scratch", Size: "2Gi", StorageClass: "fast", MountPath: "/scratch", FSType: "ext4"}},
+	})
+	doc, err := disksCloudConfig()
+	if err != nil {
+		t.Fatalf("disksCloudConfig() error = %v", err)
+	}
+	if err := validateUserData(doc); err != nil {
+		t.Errorf("disksCloudConfig() is invalid: %v\n%s", err, doc)
+	}
+	for _, want := range []string{"size_mib=1024", "fstype='xfs'", "mount_path='/data'", "label='" + workspaceLabel + "'", "alice:",
+		"size_mib=2048", "label='scratch'", "mount_path='/scratch'"} {
+		if !strings.Contains(string(doc), want) {
+			t.Errorf("disksCloudConfig() missing %q\n%s", want, doc)
+		}
+	}
+}
+
+func Test_resolveVolumes(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []string
+		want    []VolumeSettings
+		wantErr bool
+	}{
+		{
+			name:  "volumes",
+			flags: []string{"name=scratch,size=200Gi,storage-class=fast,mount=/scratch/", "name=data,size=10Gi,mount=/data,fs-type=xfs"},
+			want: []VolumeSettings{
+				{Name: "scratch", Size: "200Gi", StorageClass: "fast", MountPath: "/scratch", FSType: "ext4"},
+				{Name: "data", Size: "10Gi", StorageClass: "default", MountPath: "/data", FSType: "xfs"},
+			},
+		},
+		{
+			name:    "missing-mount",
+			flags:   []string{"name=data,size=10Gi"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown-field",
+			flags:   []string{"name=data,size=10Gi,mount=/data,mode=rw"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid-name",
+			flags:   []string{"name=Data_Disk,size=10Gi,mount=/data"},
+			wantErr: true,
+		},
+		{
+			name:    "workspace-name",
+			flags:   []string{"name=workspace,size=10Gi,mount=/data"},
+			wantErr: true,
+		},
+		{
+			name:  "workspace-size",
+			flags: []string{"name=data,size=128Gi,mount=/data"},
+			want:  []VolumeSettings{{Name: "data", Size: "128Gi", StorageClass: "default", MountPath: "/data", FSType: "ext4"}},
+		},
+		{
+			name:    "same-mount",
+			flags:   []string{"name=data,size=10Gi,mount=/data", "name=more,size=20Gi,mount=/data"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{
+				User:             "alice",
+				StorageClassName: "default",
+				Disk:             DiskSettings{Size: "128Gi", FSType: "ext4", MountPath: "/home/alice"},
+				volumeFlags:      tt.flags,
+			})
+			err := resolveVolumes()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveVolumes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(options.Volumes, tt.want) {
+				t.Errorf("resolveVolumes() got %+v, want %+v", options.Volumes, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkNewDiskSizes(t *testing.T) {
+	setOptions(t, &VMOptions{
+		Name:      "jumpbox-1",
+		Namespace: "test",
+		pvcName:   "jumpbox-1-pvc",
+		Disk:      DiskSettings{Size: "128Gi", FSType: "ext4", MountPath: "/home/alice"},
+		Volumes: []VolumeSettings{
+			{Name: "data", Size: "128Gi", StorageClass: "default", MountPath: "/data", FSType: "ext4"},
+			{Name: "scratch", Size: "10Gi", StorageClass: "default", MountPath: "/scratch", FSType: "ext4"},
+		},
+	})
+	tests := []struct {
+		name     string
+		existing []string
+		wantNew  []string
+		wantErr  bool
+	}{
+		{
+			name:    "all-new",
+			wantNew: []string{workspaceLabel, "data", "scratch"},
+			wantErr: true,
+		},
+		{
+			name:     "workspace-formatted",
+			existing: []string{"jumpbox-1-pvc"},
+			wantNew:  []string{"data", "scratch"},
+		},
+		{
+			name:     "all-formatted",
+			existing: []string{"jumpbox-1-pvc", "jumpbox-1-data-pvc", "jumpbox-1-scratch-pvc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []runtime.Object
+			for _, name := range tt.existing {
+				objects = append(objects, &corev1.PersistentVolumeClaim{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "test"}})
+			}
+			setClient(t, simpleFake.NewSimpleClientset(objects...))
+			disks, err := newDisks(context.Background())
+			if err != nil {
+				t.Fatalf("newDisks() error = %v", err)
+			}
+			var names []string
+			for _, disk := range disks {
+				names = append(names, disk.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNew) {
+				t.Errorf("newDisks() = %v, want %v", names, tt.wantNew)
+			}
+			err = checkNewDiskSizes(disks)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkNewDiskSizes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"sort"
+)
+
+// dotfiles up to dotfileInlineLimit are written by cloud-init, while the inline total stays under dotfilesInlineBudget
+// to keep the user data small. Larger files are uploaded over ssh after boot
+const (
+	dotfileInlineLimit   = 8 * 1024
+	dotfilesInlineBudget = 32 * 1024
+)
+
+// dotfilesInstallScript is run, as the jumpbox user from its home, after the dotfiles are seeded
+const dotfilesInstallScript = "install.sh"
+
+// dotfilesInstallPath is where install.sh is seeded, relative to the user home
+const dotfilesInstallPath = ".jumpbox/install.sh"
+
+// dotfile is a file of the dotfiles directory, with its path relative to the user home
+type dotfile struct {
+	Path string
+	Mode fs.FileMode
+	Data []byte
+}
+
+// dotfileSet are the files seeded in the user home, split in the ones written by cloud-init and the ones uploaded.
+// Install is set when the directory has an install.sh
+type dotfileSet struct {
+	Inline  []dotfile
+	Upload  []dotfile
+	Install bool
+}
+
+// collectDotfiles reads the regular files of dir, skipping .git. install.sh is seeded as .jumpbox/install.sh
+func collectDotfiles(dir string) (*dotfileSet, error) {
+	var files []dotfile
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, dotfile{Path: filepath.ToSlash(rel), Mode: info.Mode().Perm(), Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading dotfiles")
+	}
+	sort.SliceStable(files, func(i, j int) bool { return len(files[i].Data) < len(files[j].Data) })
+
+	dotfiles := &dotfileSet{}
+	inlineSize := 0
+	for _, f := range files {
+		if f.Path == dotfilesInstallScript {
+			f.Path = dotfilesInstallPath
+			f.Mode = 0755
+			dotfiles.Install = true
+		}
+		if len(f.Data) <= dotfileInlineLimit && inlineSize+len(f.Data) <= dotfilesInlineBudget {
+			dotfiles.Inline = append(dotfiles.Inline, f)
+			inlineSize += len(f.Data)
+			continue
+		}
+		dotfiles.Upload = append(dotfiles.Upload, f)
+	}
+	return dotfiles, nil
+}
+
+// installCommand runs install.sh as the user from its home
+func installCommand(user string) []interface{} {
+	return []interface{}{"sudo", "-u", user, "-H", "bash", "-c", "cd ~ && bash " + dotfilesInstallPath}
+}
+
+// dotfilesCloudConfig is the cloud-config, merged over the template, that writes the inline dotfiles after the user is
+// created. install.sh runs from cloud-init when there are no files to upload after boot
+func dotfilesCloudConfig(dotfiles *dotfileSet, user string) ([]byte, error) {
+	var writeFiles []interface{}
+	for _, f := range dotfiles.Inline {
+		writeFiles = append(writeFiles, map[string]interface{}{
+			"path":        path.Join("/home", user, f.Path),
+			"content":     base64.StdEncoding.EncodeToString(f.Data),
+			"encoding":    "b64",
+			"owner":       user + ":" + user,
+			"permissions": fmt.Sprintf("%04o", f.Mode),
+			"defer":       true,
+		})
+	}
+	config := map[string]interface{}{}
+	if len(writeFiles) > 0 {
+		config["write_files"] = writeFiles
+	}
+	if dotfiles.Install && len(dotfiles.Upload) == 0 {
+		config["runcmd"] = []interface{}{installCommand(user)}
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling dotfiles cloud-config")
+	}
+	return append([]byte(cloudConfigHeader+"\n"), data...), nil
+}
+
+// dotfilesArchive packages files as a tar.gz, with paths relative to the user home
+func dotfilesArchive(files []dotfile) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name: f.Path,
+			Mode: int64(f.Mode),
+			Size: int64(len(f.Data)),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "error packaging dotfiles")
+		}
+		_, err = tw.Write(f.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "error packaging dotfiles")
+		}
+	}
+	err := tw.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "error packaging dotfiles")
+	}
+	err = gz.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "error packaging dotfiles")
+	}
+	return buf.Bytes(), nil
+}
+
+// uploadDotfiles extracts files in the user home, as the user, and runs install.sh
+func uploadDotfiles(client *ssh.Client, files []dotfile, install bool, user string) error {
+	archive, err := dotfilesArchive(files)
+	if err != nil {
+		return err
+	}
+	home := fmt.Sprintf("\"$(getent passwd %[1]s | cut -d: -f6)\"", shellQuote(user))
+	_, err = runRemote(client, fmt.Sprintf("sudo -u %s -H tar -xzf - -C %s", shellQuote(user), home), bytes.NewReader(archive))
+	if err != nil {
+		return errors.WithMessage(err, "error uploading dotfiles")
+	}
+	if !install {
+		return nil
+	}
+	out, err := runRemote(client, fmt.Sprintf("sudo -u %s -H bash -c 'cd ~ && bash %s'", shellQuote(user), dotfilesInstallPath), nil)
+	fmt.Print(out)
+	if err != nil {
+		return errors.WithMessage(err, "error running dotfiles install.sh")
+	}
+	return nil
+}
+
+// dotfilesDir returns the workstation dotfiles directory, if any. A directory recorded in the spec and missing in this
+// workstation is skipped: the user home, on the workspace disk, keeps the dotfiles seeded on create
+func dotfilesDir() (string, bool) {
+	if options.dotfilesPath == "" {
+		return "", false
+	}
+	dir := localPath(options.dotfilesPath)
+	if _, err := os.Stat(dir); os.IsNotExist(err) && options.dotfilesSHA256 != "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Dotfiles directory %s not found, keeping the dotfiles of the jumpbox home\n", dir)
+		return "", false
+	}
+	return dir, true
+}
+
+// seedDotfiles uploads the dotfiles too large for the user data after boot
+func seedDotfiles(ctx context.Context) error {
+	dir, ok := dotfilesDir()
+	if !ok {
+		return nil
+	}
+	dotfiles, err := collectDotfiles(dir)
+	if err != nil {
+		return err
+	}
+	if len(dotfiles.Upload) == 0 {
+		return nil
+	}
+
+	client, err := waitSSH(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(client *ssh.Client) {
+		_ = client.Close()
+	}(client)
+
+	fmt.Printf("uploading %d dotfiles\n", len(dotfiles.Upload))
+	return uploadDotfiles(client, dotfiles.Upload, dotfiles.Install, options.User)
+}
+
+func newDotfilesCmd(ctx context.Context) *cobra.Command {
+	dotfilesCmd := &cobra.Command{
+		Use:   "dotfiles",
+		Short: "Seed the Jumpbox user home from a local directory",
+	}
+	dotfilesCmd.AddCommand(
+		newDotfilesSyncCmd(ctx),
+	)
+	return dotfilesCmd
+}
+
+func newDotfilesSyncCmd(ctx context.Context) *cobra.Command {
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Upload the dotfiles to an existing Jumpbox and run install.sh",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return SyncDotfiles(ctx)
+		}}
+	syncCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	syncCmd.Flags().StringVarP(&options.dotfilesPath, "dotfiles", "", "", "Path to the dotfiles directory. Defaults to the directory used on create")
+	_ = syncCmd.MarkFlagRequired("namespace")
+
+	return syncCmd
+}
+
+// SyncDotfiles uploads all the dotfiles to the jumpbox user home over ssh and runs install.sh
+func SyncDotfiles(ctx context.Context) error {
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return err
+	}
+	if options.dotfilesPath == "" {
+		options.dotfilesPath = spec.DotfilesDir
+	}
+	if options.dotfilesPath == "" {
+		return errors.New("jumpbox was created without dotfiles. set --dotfiles")
+	}
+	dotfiles, err := collectDotfiles(localPath(options.dotfilesPath))
+	if err != nil {
+		return err
+	}
+
+	client, err := dialJumpboxAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(client *ssh.Client) {
+		_ = client.Close()
+	}(client)
+
+	files := append(dotfiles.Inline, dotfiles.Upload...)
+	err = uploadDotfiles(client, files, dotfiles.Install, spec.User)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Synced %d dotfiles to %s\n", len(files), options.Name)
+	return nil
+}
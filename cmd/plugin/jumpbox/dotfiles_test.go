@@ -0,0 +1,116 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeDotfiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func Test_collectDotfiles(t *testing.T) {
+	dir := writeDotfiles(t, map[string]string{
+		".bashrc":            "alias k=kubectl\n",
+		".config/git/config": "[user]\n",
+		".git/HEAD":          "ref: refs/heads/main\n",
+		"install.sh":         "#!/bin/bash\n",
+		"bin/large":          strings.Repeat("x", dotfileInlineLimit+1),
+	})
+
+	got, err := collectDotfiles(dir)
+	if err != nil {
+		t.Fatalf("collectDotfiles() error = %v", err)
+	}
+	if !got.Install {
+		t.Errorf("collectDotfiles() install.sh not found")
+	}
+	var inline []string
+	for _, f := range got.Inline {
+		inline = append(inline, f.Path)
+	}
+	want := []string{".bashrc", ".config/git/config", dotfilesInstallPath}
+	if len(inline) != len(want) {
+		t.Fatalf("collectDotfiles() inline files %v, want %v", inline, want)
+	}
+	for _, p := range want {
+		if !strings.Contains(strings.Join(inline, ","), p) {
+			t.Errorf("collectDotfiles() missing inline file %s", p)
+		}
+	}
+	if len(got.Upload) != 1 || got.Upload[0].Path != "bin/large" {
+		t.Errorf("collectDotfiles() upload files %v, want bin/large", got.Upload)
+	}
+}
+
+func Test_dotfilesCloudConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantInstall bool
+	}{
+		{
+			name:        "install-from-cloud-init",
+			files:       map[string]string{".bashrc": "alias k=kubectl\n", "install.sh": "#!/bin/bash\n"},
+			wantInstall: true,
+		},
+		{
+			name:  "install-after-upload",
+			files: map[string]string{"install.sh": "#!/bin/bash\n", "bin/large": strings.Repeat("x", dotfileInlineLimit+1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dotfiles, err := collectDotfiles(writeDotfiles(t, tt.files))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := dotfilesCloudConfig(dotfiles, "alice")
+			if err != nil {
+				t.Fatalf("dotfilesCloudConfig() error = %v", err)
+			}
+			if err := validateUserData(doc); err != nil {
+				t.Errorf("dotfilesCloudConfig() is invalid: %v\n%s", err, doc)
+			}
+			if !strings.Contains(string(doc), "/home/alice/"+dotfilesInstallPath) {
+				t.Errorf("dotfilesCloudConfig() missing install.sh\n%s", doc)
+			}
+			if got := strings.Contains(string(doc), "runcmd"); got != tt.wantInstall {
+				t.Errorf("dotfilesCloudConfig() runs install.sh = %v, want %v", got, tt.wantInstall)
+			}
+		})
+	}
+}
+
+func Test_dotfilesArchive(t *testing.T) {
+	data, err := dotfilesArchive([]dotfile{{Path: ".config/git/config", Mode: 0644, Data: []byte("[user]\n")}})
+	if err != nil {
+		t.Fatalf("dotfilesArchive() error = %v", err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := tar.NewReader(gz).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Name != ".config/git/config" || header.Mode != 0644 {
+		t.Errorf("dotfilesArchive() got %s %o", header.Name, header.Mode)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// create dry run modes
+const (
+	dryRunNone   = "none"
+	dryRunClient = "client"
+	dryRunServer = "server"
+)
+
+// create dry run outputs
+const (
+	outputYAML      = "yaml"
+	outputKustomize = "kustomize"
+)
+
+// kustomizationFile is the file listing the resources of a kustomization directory
+const kustomizationFile = "kustomization.yaml"
+
+var (
+	gvrSecret = schema.GroupVersionResource{
+		Version:  "v1",
+		Resource: "secrets",
+	}
+
+	gvrPVC = schema.GroupVersionResource{
+		Version:  "v1",
+		Resource: "persistentvolumeclaims",
+	}
+
+	gvrConfigMap = schema.GroupVersionResource{
+		Version:  "v1",
+		Resource: "configmaps",
+	}
+)
+
+// createObject is an object create writes to the cluster
+type createObject struct {
+	gvr schema.GroupVersionResource
+	obj *unstructured.Unstructured
+}
+
+// Kustomization is the kustomization.yaml of the -o kustomize directory
+type Kustomization struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Resources  []string `json:"resources"`
+}
+
+func addDryRunFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&options.dryRun, "dry-run", "", dryRunNone, "`none`, `client` or `server`. client prints the objects instead of creating them, server sends them with dry run to check admission and quotas without persisting them")
+	cmd.Flags().StringVarP(&options.dryRunOutput, "output", "o", outputYAML, "Output of --dry-run=client. `yaml` prints a multi-document yaml, `kustomize` writes a kustomization to --output-dir")
+	cmd.Flags().StringVarP(&options.outputDir, "output-dir", "", "", "Directory of the -o kustomize kustomization")
+}
+
+func checkDryRunFlags() error {
+	switch options.dryRun {
+	case dryRunNone, dryRunClient, dryRunServer:
+	default:
+		return errors.Errorf("invalid dry run %q. valid values are %s, %s and %s", options.dryRun, dryRunNone, dryRunClient, dryRunServer)
+	}
+	if options.dryRun != dryRunNone && options.preflightOnly {
+		return errors.New("--dry-run and --preflight-only can't be used together")
+	}
+	switch options.dryRunOutput {
+	case outputYAML:
+	case outputKustomize:
+		if options.outputDir == "" {
+			return errors.New("--output-dir is required with -o kustomize")
+		}
+	default:
+		return errors.Errorf("invalid output %q. valid values are %s and %s", options.dryRunOutput, outputYAML, outputKustomize)
+	}
+	return nil
+}
+
+// createObjects builds the objects CreateJumpBox creates, in the order it creates them
+func createObjects() ([]createObject, error) {
+	var objects []createObject
+	add := func(gvr schema.GroupVersionResource, obj interface{}) error {
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		// drop the fields the API server sets
+		unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(u.Object, "status")
+		objects = append(objects, createObject{gvr: gvr, obj: u})
+		return nil
+	}
+
+	err := add(gvrSecret, newSSHSecret())
+	if err != nil {
+		return nil, err
+	}
+	pvc, err := newClaim(options.pvcName, options.Disk.Size, options.StorageClassName)
+	if err != nil {
+		return nil, err
+	}
+	err = add(gvrPVC, pvc)
+	if err != nil {
+		return nil, err
+	}
+	for _, volume := range options.Volumes {
+		pvc, err = newClaim(volumePVCName(volume.Name), volume.Size, volume.StorageClass)
+		if err != nil {
+			return nil, err
+		}
+		err = add(gvrPVC, pvc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if options.Bootstrap == bootstrapSecret {
+		secret, err := newBootstrapSecret()
+		if err != nil {
+			return nil, err
+		}
+		err = add(gvrSecret, secret)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cm, err := newConfigMap()
+		if err != nil {
+			return nil, err
+		}
+		err = add(gvrConfigMap, cm)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = add(gvrSvc, newSvc())
+	if err != nil {
+		return nil, err
+	}
+	err = add(gvrVM, newVM())
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// DryRun renders the objects of the jumpbox, or sends them to the API server with dry run
+func DryRun(ctx context.Context) error {
+	objects, err := createObjects()
+	if err != nil {
+		return err
+	}
+	if options.dryRun == dryRunServer {
+		return serverDryRun(ctx, objects)
+	}
+
+	if options.dryRunOutput == outputKustomize {
+		err = writeKustomization(objects, options.outputDir)
+	} else {
+		err = printObjects(objects)
+	}
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Secret %s holds the jumpbox ssh private key. Encrypt it before committing the manifests\n", options.sshSecretName)
+	return nil
+}
+
+func printObjects(objects []createObject) error {
+	for i, object := range objects {
+		data, err := yaml.Marshal(object.obj.Object)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling object")
+		}
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Print(string(data))
+	}
+	return nil
+}
+
+// writeKustomization writes one file per object and the kustomization listing them. The files listed by the
+// kustomization of a previous run are removed first, so objects that are no longer created don't linger
+func writeKustomization(objects []createObject, dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return errors.Wrap(err, "error creating output dir")
+	}
+	err = removeKustomization(dir)
+	if err != nil {
+		return err
+	}
+	kustomization := Kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+	}
+	for _, object := range objects {
+		data, err := yaml.Marshal(object.obj.Object)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling object")
+		}
+		file := strings.ToLower(object.obj.GetKind()) + "-" + object.obj.GetName() + ".yaml"
+		// the secrets hold the ssh private key and the secret values
+		err = os.WriteFile(filepath.Join(dir, file), data, 0600)
+		if err != nil {
+			return errors.Wrap(err, "error writing object")
+		}
+		kustomization.Resources = append(kustomization.Resources, file)
+	}
+	data, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling kustomization")
+	}
+	err = os.WriteFile(filepath.Join(dir, kustomizationFile), data, 0644)
+	if err != nil {
+		return errors.Wrap(err, "error writing kustomization")
+	}
+	fmt.Printf("Wrote %d objects to %s\n", len(objects), dir)
+	return nil
+}
+
+// removeKustomization removes the kustomization in dir and the resource files it lists
+func removeKustomization(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, kustomizationFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error reading kustomization")
+	}
+	previous := Kustomization{}
+	err = yaml.Unmarshal(data, &previous)
+	if err != nil {
+		return errors.Wrap(err, "error parsing kustomization")
+	}
+	for _, file := range append(previous.Resources, kustomizationFile) {
+		// only the files written next to the kustomization
+		if file != filepath.Base(file) {
+			continue
+		}
+		err = os.Remove(filepath.Join(dir, file))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "error removing previous output")
+		}
+	}
+	return nil
+}
+
+// serverDryRun sends every object with dry run, so admission and quota errors are found without persisting anything,
+// and reports all the errors at once
+func serverDryRun(ctx context.Context, objects []createObject) error {
+	var problems []string
+	for _, object := range objects {
+		_, err := dynamicClient.Resource(object.gvr).Namespace(options.Namespace).Create(ctx, object.obj, v1.CreateOptions{DryRun: []string{v1.DryRunAll}})
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s: %s", object.obj.GetKind(), object.obj.GetName(), err))
+			continue
+		}
+		fmt.Printf("%s %s created (server dry run)\n", object.obj.GetKind(), object.obj.GetName())
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("server dry run found %d problems:\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
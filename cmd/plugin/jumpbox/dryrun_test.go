@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1/install"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"testing"
+)
+
+func dryRunOptions() *VMOptions {
+	return &VMOptions{
+		Namespace:        "dev",
+		ImageName:        "ubuntu-2004",
+		ClassName:        "best-effort-small",
+		StorageClassName: "gold",
+		NetworkType:      networkTypeNSXT,
+		Transport:        transportOvfEnv,
+		Bootstrap:        bootstrapConfigMap,
+		UserData:         "I2Nsb3VkLWNvbmZpZwo=",
+		SSHPublicKey:     "ssh-rsa AAAA",
+		SSHPrivateKey:    "private",
+		Disk:             DiskSettings{Size: "64Gi", FSType: "ext4"},
+		Volumes:          []VolumeSettings{{Name: "scratch", Size: "200Gi", StorageClass: "fast", MountPath: "/scratch", FSType: "ext4"}},
+	}
+}
+
+func Test_createObjects(t *testing.T) {
+	setOptions(t, dryRunOptions())
+	setup([]string{"jumpbox-1"})
+	objects, err := createObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, object := range objects {
+		got = append(got, object.obj.GetKind()+"/"+object.obj.GetName())
+		if object.obj.GetAPIVersion() == "" || object.obj.GetNamespace() != "dev" {
+			t.Errorf("createObjects() %s has no apiVersion or namespace", object.obj.GetName())
+		}
+		if _, ok := object.obj.Object["status"]; ok {
+			t.Errorf("createObjects() %s has a status", object.obj.GetName())
+		}
+		if _, ok := object.obj.Object["metadata"].(map[string]interface{})["creationTimestamp"]; ok {
+			t.Errorf("createObjects() %s has a creationTimestamp", object.obj.GetName())
+		}
+	}
+	want := []string{
+		"Secret/jumpbox-1-ssh",
+		"PersistentVolumeClaim/jumpbox-1-pvc",
+		"PersistentVolumeClaim/" + volumePVCName("scratch"),
+		"ConfigMap/jumpbox-1-cm",
+		"VirtualMachineService/jumpbox-1-svc",
+		"VirtualMachine/jumpbox-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("createObjects() = %v, want %v", got, want)
+	}
+}
+
+func Test_writeKustomization(t *testing.T) {
+	setOptions(t, dryRunOptions())
+	setup([]string{"jumpbox-1"})
+	objects, err := createObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "jumpbox-1")
+	err = writeKustomization(objects, dir)
+	if err != nil {
+		t.Fatalf("writeKustomization() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, kustomizationFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kustomization := Kustomization{}
+	err = yaml.UnmarshalStrict(data, &kustomization)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kustomization.Resources) != len(objects) {
+		t.Fatalf("kustomization resources = %v, want %d", kustomization.Resources, len(objects))
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "virtualmachine-jumpbox-1.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := v1alpha1.VirtualMachine{}
+	err = yaml.Unmarshal(data, &vm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Spec.ClassName != "best-effort-small" || vm.Spec.VmMetadata.ConfigMapName != "jumpbox-1-cm" || len(vm.Spec.Volumes) != 2 {
+		t.Errorf("written vm spec = %+v", vm.Spec)
+	}
+
+	// a second run without the vm removes the stale vm file and keeps the files the plugin didn't write
+	err = os.WriteFile(filepath.Join(dir, "README.md"), []byte("notes"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeKustomization(objects[:len(objects)-1], dir)
+	if err != nil {
+		t.Fatalf("writeKustomization() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "virtualmachine-jumpbox-1.yaml")); !os.IsNotExist(err) {
+		t.Errorf("stale vm file was not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "README.md")); err != nil {
+		t.Errorf("unrelated file was removed: %v", err)
+	}
+}
+
+func Test_serverDryRun(t *testing.T) {
+	setOptions(t, dryRunOptions())
+	setup([]string{"jumpbox-1"})
+	objects, err := createObjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	_ = corev1.AddToScheme(scheme)
+	existing := &v1alpha1.VirtualMachine{ObjectMeta: v1.ObjectMeta{Name: "jumpbox-1", Namespace: "dev"}}
+	setDynamicClient(t, fake.NewSimpleDynamicClient(scheme, existing))
+
+	err = serverDryRun(context.Background(), objects)
+	if err == nil || !strings.Contains(err.Error(), "server dry run found 1 problems") || !strings.Contains(err.Error(), "VirtualMachine jumpbox-1") {
+		t.Errorf("serverDryRun() error = %v, want the existing VirtualMachine reported", err)
+	}
+}
+
+func Test_checkDryRunFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		options VMOptions
+		wantErr bool
+	}{
+		{name: "none", options: VMOptions{dryRun: dryRunNone, dryRunOutput: outputYAML}},
+		{name: "client", options: VMOptions{dryRun: dryRunClient, dryRunOutput: outputYAML}},
+		{name: "kustomize", options: VMOptions{dryRun: dryRunClient, dryRunOutput: outputKustomize, outputDir: "out"}},
+		{name: "kustomize-no-dir", options: VMOptions{dryRun: dryRunClient, dryRunOutput: outputKustomize}, wantErr: true},
+		{name: "invalid", options: VMOptions{dryRun: "all", dryRunOutput: outputYAML}, wantErr: true},
+		{name: "preflight-only", options: VMOptions{dryRun: dryRunServer, dryRunOutput: outputYAML, preflightOnly: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &tt.options)
+			if err := checkDryRunFlags(); (err != nil) != tt.wantErr {
+				t.Errorf("checkDryRunFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"time"
+)
+
+const expandTimeout = 5 * time.Minute
+
+// growFilesystemScript rescans the disk with the filesystem label, grows its partition when it has one and grows the
+// filesystem online. The last output line is the filesystem size in bytes before and after
+const growFilesystemScript = `set -eu
+label=%s
+dev=$(blkid -L "$label") || { echo "no filesystem labelled $label" >&2; exit 1; }
+mount_path=$(findmnt -no TARGET "$dev" | head -n 1)
+[ -n "$mount_path" ] || { echo "$dev is not mounted" >&2; exit 1; }
+before=$(df -B1 --output=size "$mount_path" | tail -n 1 | tr -d ' ')
+disk=$(lsblk -npo PKNAME "$dev" | head -n 1)
+if [ -n "$disk" ]; then
+  echo 1 > "/sys/class/block/$(basename "$disk")/device/rescan"
+  growpart "$disk" "$(cat "/sys/class/block/$(basename "$dev")/partition")" || [ $? -eq 1 ]
+else
+  echo 1 > "/sys/class/block/$(basename "$dev")/device/rescan"
+fi
+case "$(findmnt -no FSTYPE "$dev" | head -n 1)" in
+  xfs) xfs_growfs "$mount_path" ;;
+  ext*) resize2fs "$dev" ;;
+  *) echo "unsupported filesystem on $dev" >&2; exit 1 ;;
+esac
+after=$(df -B1 --output=size "$mount_path" | tail -n 1 | tr -d ' ')
+echo "$before $after"
+`
+
+func newExpandDiskCmd(ctx context.Context) *cobra.Command {
+	expandCmd := &cobra.Command{
+		Use:   "expand-disk",
+		Short: "Grow the Jumpbox workspace disk and its filesystem",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ExpandDisk(ctx)
+		}}
+	expandCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	expandCmd.Flags().StringVarP(&options.Disk.Size, "size", "", "", "New disk size, a whole number of MiB. Disks not formatted yet are found by size, so it must differ from the size of the other disks")
+	expandCmd.Flags().StringVarP(&options.expandVolume, "volume", "", "", "Name of the extra volume to expand instead of the workspace disk")
+	_ = expandCmd.MarkFlagRequired("namespace")
+	_ = expandCmd.MarkFlagRequired("size")
+
+	return expandCmd
+}
+
+// ExpandDisk grows the PVC of the workspace disk, or of an extra volume, and then its filesystem over ssh.
+// Running it again with the same size only grows the filesystem
+func ExpandDisk(ctx context.Context) error {
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return err
+	}
+	size, err := parseDiskSize(options.Disk.Size)
+	if err != nil {
+		return err
+	}
+
+	claim, label := options.pvcName, workspaceLabel
+	if options.expandVolume != "" {
+		found := false
+		for _, volume := range spec.Volumes {
+			found = found || volume.Name == options.expandVolume
+		}
+		if !found {
+			return errors.Errorf("jumpbox %s has no volume %q", options.Name, options.expandVolume)
+		}
+		claim, label = volumePVCName(options.expandVolume), options.expandVolume
+	}
+	err = checkExpandedSize(spec, label, size)
+	if err != nil {
+		return err
+	}
+
+	before, err := resizePVC(ctx, claim, size)
+	if err != nil {
+		return err
+	}
+	if before.Cmp(size) < 0 {
+		err = waitResize(ctx, claim, size)
+		if err != nil {
+			return err
+		}
+	}
+	err = updateSpecDiskSize(ctx, spec, size)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Persistent Volume %s: %s -> %s\n", claim, before.String(), size.String())
+
+	vm, err := getVM(ctx)
+	if err != nil {
+		return err
+	}
+	if vm.Status.PowerState != "poweredOn" {
+		fmt.Printf("Jumpbox %s is %s. Power it on and run expand-disk again to grow the filesystem\n", options.Name, vm.Status.PowerState)
+		return nil
+	}
+
+	client, err := dialJumpboxAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(client *ssh.Client) {
+		_ = client.Close()
+	}(client)
+
+	fsBefore, fsAfter, err := growFilesystem(client, label)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Filesystem %s: %s -> %s\n", label, formatBytes(fsBefore), formatBytes(fsAfter))
+	if fsAfter <= fsBefore && before.Cmp(size) < 0 {
+		fmt.Println("The disk didn't grow in the guest. The storage provider may need a power cycle to expand attached disks: " +
+			"run power-off and power-on, then expand-disk again")
+	}
+	return nil
+}
+
+// resizePVC patches the PVC storage request to size when its storage class allows expansion, and returns the previous
+// request. Shrinking is an error
+func resizePVC(ctx context.Context, claim string, size resource.Quantity) (resource.Quantity, error) {
+	pvc, err := c.CoreV1().PersistentVolumeClaims(options.Namespace).Get(ctx, claim, v1.GetOptions{})
+	if err != nil {
+		return resource.Quantity{}, errors.Wrap(err, "error getting pvc")
+	}
+	before := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	switch before.Cmp(size) {
+	case 0:
+		return before, nil
+	case 1:
+		return before, errors.Errorf("pvc %s is %s, volumes can't shrink to %s", claim, before.String(), size.String())
+	}
+
+	if pvc.Spec.StorageClassName == nil {
+		return before, errors.Errorf("pvc %s has no storage class", claim)
+	}
+	sc, err := c.StorageV1().StorageClasses().Get(ctx, *pvc.Spec.StorageClassName, v1.GetOptions{})
+	if err != nil {
+		return before, errors.Wrap(err, "error getting storage class")
+	}
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		return before, errors.Errorf("storage class %s doesn't allow volume expansion", sc.Name)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"resources": map[string]interface{}{
+				"requests": map[string]interface{}{
+					string(corev1.ResourceStorage): size.String(),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return before, errors.Wrap(err, "err marshaling")
+	}
+	_, err = c.CoreV1().PersistentVolumeClaims(options.Namespace).Patch(ctx, claim, types.MergePatchType, patch, v1.PatchOptions{})
+	if err != nil {
+		return before, errors.Wrap(err, "error patching pvc")
+	}
+	return before, nil
+}
+
+// waitResize waits until the PVC capacity reaches size or the volume is resized and only the filesystem resize is
+// pending, which is done by expand-disk in the guest
+func waitResize(ctx context.Context, claim string, size resource.Quantity) error {
+	fmt.Print("waiting for volume resize ")
+	deadline := time.Now().Add(expandTimeout)
+	for {
+		pvc, err := c.CoreV1().PersistentVolumeClaims(options.Namespace).Get(ctx, claim, v1.GetOptions{})
+		if err != nil {
+			return errors.Wrap(err, "error getting pvc")
+		}
+		if resized(pvc, size) {
+			fmt.Println()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			fmt.Println()
+			return errors.Errorf("pvc %s was not resized in %s%s. The storage provider may only expand disks of powered off VMs: "+
+				"run power-off, wait for the resize and run power-on, then expand-disk again to grow the filesystem",
+				claim, expandTimeout, resizeConditions(pvc))
+		}
+		fmt.Print(".")
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func resized(pvc *corev1.PersistentVolumeClaim, size resource.Quantity) bool {
+	capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+	if ok && capacity.Cmp(size) >= 0 {
+		return true
+	}
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Type == corev1.PersistentVolumeClaimFileSystemResizePending && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// resizeConditions describes the PVC conditions with a message, like resize errors of the storage provider
+func resizeConditions(pvc *corev1.PersistentVolumeClaim) string {
+	var messages []string
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Message != "" {
+			messages = append(messages, fmt.Sprintf("%s: %s", condition.Type, condition.Message))
+		}
+	}
+	if len(messages) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(messages, "; ") + ")"
+}
+
+// checkExpandedSize validates the new size of the disk with label against the other disks of the spec. A jumpbox that
+// never booted has its disks not formatted yet, found by size, so the expanded disk can't take the size of another one
+func checkExpandedSize(spec *JumpboxSpec, label string, size resource.Quantity) error {
+	others := spec.Volumes
+	if spec.Disk != nil {
+		others = append([]VolumeSettings{{Name: workspaceLabel, Size: spec.Disk.Size}}, spec.Volumes...)
+	}
+	for _, disk := range others {
+		if disk.Name == label {
+			continue
+		}
+		q, err := resource.ParseQuantity(disk.Size)
+		if err == nil && q.Cmp(size) == 0 {
+			return errors.Errorf("%s would have the same size as %s. disks not formatted yet are found by size, use a different size", label, disk.Name)
+		}
+	}
+	return nil
+}
+
+// updateSpecDiskSize records the new size of the expanded disk in the jumpbox spec
+func updateSpecDiskSize(ctx context.Context, spec *JumpboxSpec, size resource.Quantity) error {
+	if options.expandVolume == "" {
+		if spec.Disk == nil {
+			spec.Disk = &DiskSettings{}
+		}
+		spec.Disk.Size = size.String()
+	}
+	for i, volume := range spec.Volumes {
+		if volume.Name == options.expandVolume {
+			spec.Volumes[i].Size = size.String()
+		}
+	}
+	return patchJumpboxSpec(ctx, spec, nil)
+}
+
+// growFilesystem grows the filesystem with label in the guest and returns its size in bytes before and after
+func growFilesystem(client *ssh.Client, label string) (int64, int64, error) {
+	out, err := runRemote(client, "sudo sh -s", strings.NewReader(fmt.Sprintf(growFilesystemScript, shellQuote(label))))
+	if err != nil {
+		return 0, 0, errors.WithMessage(err, "error growing filesystem")
+	}
+	return parseGrowOutput(out)
+}
+
+// parseGrowOutput reads the sizes in the last line of the grow filesystem script output
+func parseGrowOutput(out string) (int64, int64, error) {
+	var before, after int64
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	_, err := fmt.Sscanf(lines[len(lines)-1], "%d %d", &before, &after)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, fmt.Sprintf("error reading filesystem size: %s", out))
+	}
+	return before, after, nil
+}
+
+// formatBytes formats a size in GiB
+func formatBytes(size int64) string {
+	return fmt.Sprintf("%.1fGi", float64(size)/(1<<30))
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	simpleFake "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func Test_resizePVC(t *testing.T) {
+	ctx := context.Background()
+	allow, deny := true, false
+	tests := []struct {
+		name      string
+		expansion *bool
+		size      string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "expand",
+			expansion: &allow,
+			size:      "256Gi",
+			want:      "256Gi",
+		},
+		{
+			name:      "same-size",
+			expansion: &deny,
+			size:      "128Gi",
+			want:      "128Gi",
+		},
+		{
+			name:      "expansion-not-allowed",
+			expansion: &deny,
+			size:      "256Gi",
+			wantErr:   true,
+		},
+		{
+			name:    "expansion-unset",
+			size:    "256Gi",
+			wantErr: true,
+		},
+		{
+			name:      "shrink",
+			expansion: &allow,
+			size:      "64Gi",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{Namespace: "test"})
+			storageClass := "fast"
+			setClient(t, simpleFake.NewSimpleClientset(
+				&storagev1.StorageClass{ObjectMeta: v1.ObjectMeta{Name: storageClass}, AllowVolumeExpansion: tt.expansion},
+				&corev1.PersistentVolumeClaim{
+					ObjectMeta: v1.ObjectMeta{Name: "test-pvc", Namespace: "test"},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						StorageClassName: &storageClass,
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("128Gi")},
+						},
+					},
+				},
+			))
+			before, err := resizePVC(ctx, "test-pvc", resource.MustParse(tt.size))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resizePVC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if before.String() != "128Gi" {
+				t.Errorf("resizePVC() before = %s, want 128Gi", before.String())
+			}
+			pvc, err := c.CoreV1().PersistentVolumeClaims("test").Get(ctx, "test-pvc", v1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+			if got.String() != tt.want {
+				t.Errorf("resizePVC() request = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_resized(t *testing.T) {
+	size := resource.MustParse("256Gi")
+	tests := []struct {
+		name   string
+		status corev1.PersistentVolumeClaimStatus
+		want   bool
+	}{
+		{
+			name:   "capacity",
+			status: corev1.PersistentVolumeClaimStatus{Capacity: corev1.ResourceList{corev1.ResourceStorage: size}},
+			want:   true,
+		},
+		{
+			name: "filesystem-resize-pending",
+			status: corev1.PersistentVolumeClaimStatus{
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("128Gi")},
+				Conditions: []corev1.PersistentVolumeClaimCondition{{
+					Type:   corev1.PersistentVolumeClaimFileSystemResizePending,
+					Status: corev1.ConditionTrue,
+				}},
+			},
+			want: true,
+		},
+		{
+			name: "resizing",
+			status: corev1.PersistentVolumeClaimStatus{
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("128Gi")},
+				Conditions: []corev1.PersistentVolumeClaimCondition{{
+					Type:   corev1.PersistentVolumeClaimResizing,
+					Status: corev1.ConditionTrue,
+				}},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resized(&corev1.PersistentVolumeClaim{Status: tt.status}, size); got != tt.want {
+				t.Errorf("resized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseGrowOutput(t *testing.T) {
+	out := "resize2fs 1.46.5 (30-Dec-2021)\nThe filesystem on /dev/sdb is now 67108864 (4k) blocks long.\n\n134217728000 268435456000\n"
+	before, after, err := parseGrowOutput(out)
+	if err != nil {
+		t.Fatalf("parseGrowOutput() error = %v", err)
+	}
+	if before != 134217728000 || after != 268435456000 {
+		t.Errorf("parseGrowOutput() = %d %d", before, after)
+	}
+	if _, _, err := parseGrowOutput("error"); err == nil {
+		t.Errorf("parseGrowOutput() expected error")
+	}
+}
+
+func Test_checkExpandedSize(t *testing.T) {
+	spec := &JumpboxSpec{
+		Disk: &DiskSettings{Size: "128Gi"},
+		Volumes: []VolumeSettings{
+			{Name: "data", Size: "10Gi"},
+			{Name: "scratch", Size: "200Gi"},
+		},
+	}
+	tests := []struct {
+		name    string
+		label   string
+		size    string
+		wantErr bool
+	}{
+		{name: "workspace", label: workspaceLabel, size: "256Gi"},
+		{name: "workspace-same-size-as-volume", label: workspaceLabel, size: "200Gi", wantErr: true},
+		{name: "volume", label: "data", size: "20Gi"},
+		{name: "volume-same-size-as-workspace", label: "data", size: "128Gi", wantErr: true},
+		{name: "volume-same-size-as-volume", label: "data", size: "200Gi", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkExpandedSize(spec, tt.label, resource.MustParse(tt.size))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkExpandedSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
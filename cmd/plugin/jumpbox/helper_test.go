@@ -0,0 +1,66 @@
+package main
+
+import (
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"testing"
+)
+
+// setOptions replaces the options for the test, with the plugin config in a temporary dir unless tanzuDir is set, and
+// restores them when the test ends
+func setOptions(t *testing.T, o *VMOptions) {
+	t.Helper()
+	saved := options
+	t.Cleanup(func() {
+		options = saved
+	})
+	if o.tanzuDir == "" {
+		o.tanzuDir = t.TempDir()
+	}
+	options = o
+}
+
+// setClient replaces the kubernetes client for the test and restores it when the test ends
+func setClient(t *testing.T, client kubernetes.Interface) {
+	t.Helper()
+	saved := c
+	t.Cleanup(func() {
+		c = saved
+	})
+	c = client
+}
+
+// setDynamicClient replaces the dynamic client for the test and restores it when the test ends
+func setDynamicClient(t *testing.T, client dynamic.Interface) {
+	t.Helper()
+	saved := dynamicClient
+	t.Cleanup(func() {
+		dynamicClient = saved
+	})
+	dynamicClient = client
+}
+
+// pinBuiltinTools overrides the built-in tools in the user tool manifest of options.tanzuDir with a test sha256, for
+// the tests that render the install script of the built-in tools
+func pinBuiltinTools(t *testing.T) {
+	t.Helper()
+	manifest := &ToolManifest{}
+	err := yaml.Unmarshal(builtinToolManifest, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range manifest.Tools {
+		manifest.Tools[i].SHA256 = strings.Repeat("0", 64)
+	}
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(userToolManifestPath(), data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
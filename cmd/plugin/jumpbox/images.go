@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"strings"
+)
+
+var gvrVMImage = schema.GroupVersionResource{
+	Group:    "vmoperator.vmware.com",
+	Version:  "v1alpha1",
+	Resource: "virtualmachineimages",
+}
+
+// CloudUserRule maps an image OS to the default user of its cloud-init config.
+// Match is a case insensitive substring of the image os type, product or name
+type CloudUserRule struct {
+	Match string `json:"match"`
+	User  string `json:"user"`
+}
+
+// defaultCloudUsers are the default cloud-init users of the supported distros
+var defaultCloudUsers = []CloudUserRule{
+	{Match: "ubuntu", User: "ubuntu"},
+	{Match: "centos", User: "cloud-user"},
+	{Match: "rhel", User: "cloud-user"},
+	{Match: "red hat", User: "cloud-user"},
+	{Match: "photon", User: "root"},
+	{Match: "rocky", User: "rocky"},
+	{Match: "debian", User: "debian"},
+}
+
+// fallbackCloudUser is used when the image OS is not in the table
+const fallbackCloudUser = "ubuntu"
+
+// getVMImage gets the cluster scoped VirtualMachineImage
+func getVMImage(ctx context.Context, imageName string) (*v1alpha1.VirtualMachineImage, error) {
+	res, err := dynamicClient.Resource(gvrVMImage).Get(ctx, imageName, v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	image := &v1alpha1.VirtualMachineImage{}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, image)
+	if err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// imageOS describes the image OS from its metadata, falling back to the image name
+func imageOS(image *v1alpha1.VirtualMachineImage) string {
+	return strings.ToLower(strings.Join([]string{
+		image.Spec.OSInfo.Type,
+		image.Spec.OSInfo.Version,
+		image.Spec.ProductInfo.Product,
+		image.Spec.ProductInfo.FullVersion,
+		image.Name,
+	}, " "))
+}
+
+// matchCloudUser returns the user of the first rule matching osName, config rules first
+func matchCloudUser(osName string, config *PluginConfig) (string, bool) {
+	rules := append(append([]CloudUserRule{}, config.CloudUsers...), defaultCloudUsers...)
+	for _, rule := range rules {
+		if rule.Match != "" && strings.Contains(osName, strings.ToLower(rule.Match)) {
+			return rule.User, true
+		}
+	}
+	return "", false
+}
+
+// cloudUser returns the default user of the image cloud-init config, which always has passwordless sudo.
+// The OS is read from the VirtualMachineImage metadata, or guessed from the image name when the image was deleted
+func cloudUser(ctx context.Context, imageName string, config *PluginConfig) (string, error) {
+	image, err := getVMImage(ctx, imageName)
+	if apierrors.IsNotFound(err) {
+		image = &v1alpha1.VirtualMachineImage{ObjectMeta: v1.ObjectMeta{Name: imageName}}
+	} else if err != nil {
+		return "", errors.Wrap(err, "error getting vm image")
+	}
+	return imageCloudUser(image, config), nil
+}
+
+// imageCloudUser returns the default cloud-init user of the image OS
+func imageCloudUser(image *v1alpha1.VirtualMachineImage, config *PluginConfig) string {
+	if user, ok := matchCloudUser(imageOS(image), config); ok {
+		return user
+	}
+	return fallbackCloudUser
+}
+
+// netplanMinUbuntu is the first Ubuntu release with netplan
+const netplanMinUbuntu = "18.04"
+
+// imageHasNetplan tells the image OS has netplan, which applies the network config of the --nic interfaces. Ubuntu has
+// it since 18.04, the other distros use their own network config. An Ubuntu image without a version is assumed recent
+func imageHasNetplan(image *v1alpha1.VirtualMachineImage) bool {
+	if !strings.Contains(imageOS(image), "ubuntu") {
+		return false
+	}
+	version := imageVersion(image)
+	return len(versionNumbers(version)) == 0 || compareVersions(version, netplanMinUbuntu) >= 0
+}
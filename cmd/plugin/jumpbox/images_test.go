@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1/install"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+)
+
+func Test_matchCloudUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		image  v1alpha1.VirtualMachineImage
+		config PluginConfig
+		want   string
+		wantOk bool
+	}{
+		{
+			name: "ubuntu-os-type",
+			image: v1alpha1.VirtualMachineImage{
+				ObjectMeta: v1.ObjectMeta{Name: "ubuntu-20-1633387172196"},
+				Spec: v1alpha1.VirtualMachineImageSpec{
+					OSInfo: v1alpha1.VirtualMachineImageOSInfo{Type: "ubuntu64Guest"},
+				},
+			},
+			want:   "ubuntu",
+			wantOk: true,
+		},
+		{
+			name: "rhel-from-product",
+			image: v1alpha1.VirtualMachineImage{
+				ObjectMeta: v1.ObjectMeta{Name: "vmi-0a1b2c"},
+				Spec: v1alpha1.VirtualMachineImageSpec{
+					ProductInfo: v1alpha1.VirtualMachineImageProductInfo{Product: "Red Hat Enterprise Linux 8"},
+				},
+			},
+			want:   "cloud-user",
+			wantOk: true,
+		},
+		{
+			name: "config-override",
+			image: v1alpha1.VirtualMachineImage{
+				ObjectMeta: v1.ObjectMeta{Name: "ubuntu-20-custom"},
+				Spec: v1alpha1.VirtualMachineImageSpec{
+					OSInfo: v1alpha1.VirtualMachineImageOSInfo{Type: "ubuntu64Guest"},
+				},
+			},
+			config: PluginConfig{CloudUsers: []CloudUserRule{{Match: "custom", User: "admin"}}},
+			want:   "admin",
+			wantOk: true,
+		},
+		{
+			name: "unknown",
+			image: v1alpha1.VirtualMachineImage{
+				ObjectMeta: v1.ObjectMeta{Name: "windows-2019"},
+				Spec: v1alpha1.VirtualMachineImageSpec{
+					OSInfo: v1alpha1.VirtualMachineImageOSInfo{Type: "windows9Server64Guest"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchCloudUser(imageOS(&tt.image), &tt.config)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("matchCloudUser() got (%s, %v), want (%s, %v)", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_cloudUser(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	client := fake.NewSimpleDynamicClient(scheme, &v1alpha1.VirtualMachineImage{
+		TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineImage", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+		ObjectMeta: v1.ObjectMeta{Name: "vmi-0a1b2c"},
+		Spec: v1alpha1.VirtualMachineImageSpec{
+			ProductInfo: v1alpha1.VirtualMachineImageProductInfo{Product: "Red Hat Enterprise Linux 8"},
+		},
+	})
+	setDynamicClient(t, client)
+	config := &PluginConfig{}
+
+	got, err := cloudUser(ctx, "vmi-0a1b2c", config)
+	if err != nil || got != "cloud-user" {
+		t.Errorf("cloudUser() got (%s, %v), want cloud-user", got, err)
+	}
+	got, err = cloudUser(ctx, "photon-deleted", config)
+	if err != nil || got != "root" {
+		t.Errorf("cloudUser() of a missing image got (%s, %v), want root from its name", got, err)
+	}
+
+	client.PrependReactor("get", "virtualmachineimages", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	_, err = cloudUser(ctx, "vmi-0a1b2c", config)
+	if err == nil {
+		t.Errorf("cloudUser() want error when the image can't be read")
+	}
+}
+
+func Test_imageHasNetplan(t *testing.T) {
+	image := func(name string, osType string, version string) *v1alpha1.VirtualMachineImage {
+		return &v1alpha1.VirtualMachineImage{
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec: v1alpha1.VirtualMachineImageSpec{
+				OSInfo: v1alpha1.VirtualMachineImageOSInfo{Type: osType, Version: version},
+			},
+		}
+	}
+	tests := []struct {
+		name  string
+		image *v1alpha1.VirtualMachineImage
+		want  bool
+	}{
+		{name: "ubuntu-from-name", image: image("ubuntu-20-1633387172196", "ubuntu64Guest", ""), want: true},
+		{name: "ubuntu-version", image: image("vmi-0a1b2c", "ubuntu64Guest", "22.04"), want: true},
+		{name: "ubuntu-without-version", image: image("ubuntu-jammy", "ubuntu64Guest", ""), want: true},
+		{name: "old-ubuntu", image: image("ubuntu-16.04-cloud", "ubuntu64Guest", "")},
+		{name: "centos", image: image("centos-stream-8", "centos8_64Guest", "")},
+		{name: "photon", image: image("photon-4", "vmwarePhoton64Guest", "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imageHasNetplan(tt.image); got != tt.want {
+				t.Errorf("imageHasNetplan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
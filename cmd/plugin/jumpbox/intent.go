@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var versionNumberRegex = regexp.MustCompile(`\d+`)
+
+// intentFlags are the flags resolved to the image and class flags
+var intentFlags = map[string][]string{
+	"image": {"os"},
+	"class": {"cpus", "memory"},
+}
+
+func addIntentFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&options.OS, "os", "", "", "Use the newest image of `distro[:version]` in the namespace instead of --image, e.g. ubuntu:20.04")
+	cmd.Flags().IntVarP(&options.CPUs, "cpus", "", 0, "Use the smallest class bound to the namespace with at least these CPUs instead of --class")
+	cmd.Flags().StringVarP(&options.Memory, "memory", "", "", "Use the smallest class bound to the namespace with at least this memory instead of --class")
+}
+
+// checkIntentFlags checks the image and class are not given together with the flags resolved to them
+func checkIntentFlags(cmd *cobra.Command) error {
+	for _, flag := range []string{"image", "class"} {
+		for _, intent := range intentFlags[flag] {
+			if cmd.Flags().Changed(flag) && cmd.Flags().Changed(intent) {
+				return errors.Errorf("--%s and --%s can't be used together", flag, intent)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveIntent sets the image flag from --os and the class flag from --cpus and --memory
+func resolveIntent(ctx context.Context, cmd *cobra.Command) error {
+	image, class := options.ImageName, options.ClassName
+	err := resolveIntentOptions(ctx)
+	if err != nil {
+		return err
+	}
+	if options.ImageName != image {
+		err = cmd.Flags().Set("image", options.ImageName)
+		if err != nil {
+			return err
+		}
+	}
+	if options.ClassName != class {
+		err = cmd.Flags().Set("class", options.ClassName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveIntentOptions sets the image from the OS and the class from the CPUs and memory when they are not set, and
+// prints the names resolved
+func resolveIntentOptions(ctx context.Context) error {
+	if options.OS != "" && options.ImageName == "" {
+		image, err := resolveImage(ctx, options.OS)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Resolved --os %s to image %s\n", options.OS, image)
+		options.ImageName = image
+	}
+	if (options.CPUs > 0 || options.Memory != "") && options.ClassName == "" {
+		class, err := resolveClass(ctx, options.CPUs, options.Memory)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Resolved %s to class %s\n", classIntent(options.CPUs, options.Memory), class)
+		options.ClassName = class
+	}
+	return nil
+}
+
+// resolveImage returns the newest image of the namespace matching distro[:version]. The distro matches the image OS
+// type, product or name, and the version matches the leading numbers of the image version. Images with the same
+// version are ordered by creation time
+func resolveImage(ctx context.Context, osIntent string) (string, error) {
+	distro, version := osIntent, ""
+	if i := strings.Index(osIntent, ":"); i >= 0 {
+		distro, version = osIntent[:i], osIntent[i+1:]
+	}
+	distro = strings.ToLower(distro)
+	if distro == "" {
+		return "", errors.Errorf("invalid --os %q, expected distro[:version]", osIntent)
+	}
+
+	images, err := boundImages(ctx)
+	if err != nil {
+		return "", err
+	}
+	var matches []v1alpha1.VirtualMachineImage
+	for _, image := range images {
+		if strings.Contains(imageOS(&image), distro) && versionMatches(imageVersion(&image), version) {
+			matches = append(matches, image)
+		}
+	}
+	if len(matches) == 0 {
+		return "", errors.Errorf("no image in namespace %s matches --os %s", options.Namespace, osIntent)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if c := compareVersions(imageVersion(&matches[i]), imageVersion(&matches[j])); c != 0 {
+			return c > 0
+		}
+		return matches[j].CreationTimestamp.Before(&matches[i].CreationTimestamp)
+	})
+	return matches[0].Name, nil
+}
+
+// imageVersion is the OS version of the image, or the image name when it has no version metadata, as content
+// library item names usually have the version
+func imageVersion(image *v1alpha1.VirtualMachineImage) string {
+	for _, version := range []string{image.Spec.OSInfo.Version, image.Spec.ProductInfo.Version, image.Spec.ProductInfo.FullVersion} {
+		if version != "" {
+			return version
+		}
+	}
+	return image.Name
+}
+
+func versionNumbers(version string) []int64 {
+	var numbers []int64
+	for _, n := range versionNumberRegex.FindAllString(version, -1) {
+		i, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, i)
+	}
+	return numbers
+}
+
+// versionMatches checks the leading numbers of version are the numbers of want, so 20.04 matches 20.04.3
+func versionMatches(version string, want string) bool {
+	wantNumbers := versionNumbers(want)
+	numbers := versionNumbers(version)
+	if len(wantNumbers) > len(numbers) {
+		return false
+	}
+	for i := range wantNumbers {
+		if wantNumbers[i] != numbers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func compareVersions(a string, b string) int {
+	an, bn := versionNumbers(a), versionNumbers(b)
+	for i := 0; i < len(an) && i < len(bn); i++ {
+		if an[i] != bn[i] {
+			if an[i] > bn[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	return len(an) - len(bn)
+}
+
+// resolveClass returns the smallest class bound to the namespace with at least cpus and memory, by CPUs and then
+// memory
+func resolveClass(ctx context.Context, cpus int, memory string) (string, error) {
+	var minMemory resource.Quantity
+	if memory != "" {
+		var err error
+		minMemory, err = resource.ParseQuantity(memory)
+		if err != nil {
+			return "", errors.Wrap(err, fmt.Sprintf("invalid --memory %q", memory))
+		}
+	}
+
+	classes, err := listClasses(ctx)
+	if err != nil {
+		return "", err
+	}
+	var matches []ClassInfo
+	for _, class := range classes {
+		classMemory, err := resource.ParseQuantity(class.Memory)
+		if err != nil {
+			continue
+		}
+		if class.CPUs >= int64(cpus) && classMemory.Cmp(minMemory) >= 0 {
+			matches = append(matches, class)
+		}
+	}
+	if len(matches) == 0 {
+		return "", errors.Errorf("no class bound to namespace %s satisfies %s", options.Namespace, classIntent(cpus, memory))
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].CPUs != matches[j].CPUs {
+			return matches[i].CPUs < matches[j].CPUs
+		}
+		mi, mj := resource.MustParse(matches[i].Memory), resource.MustParse(matches[j].Memory)
+		return mi.Cmp(mj) < 0
+	})
+	return matches[0].Name, nil
+}
+
+func classIntent(cpus int, memory string) string {
+	var intent []string
+	if cpus > 0 {
+		intent = append(intent, fmt.Sprintf("--cpus %d", cpus))
+	}
+	if memory != "" {
+		intent = append(intent, "--memory "+memory)
+	}
+	return strings.Join(intent, " ")
+}
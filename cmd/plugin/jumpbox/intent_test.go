@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1/install"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+	"testing"
+	"time"
+)
+
+func Test_resolveImage(t *testing.T) {
+	ctx := context.Background()
+	created := time.Now()
+	image := func(name string, product string, version string, age time.Duration) runtime.Object {
+		return &v1alpha1.VirtualMachineImage{
+			TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineImage", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: name, CreationTimestamp: v1.NewTime(created.Add(-age))},
+			Spec: v1alpha1.VirtualMachineImageSpec{
+				ProviderRef: v1alpha1.ContentProviderReference{Name: "library"},
+				ProductInfo: v1alpha1.VirtualMachineImageProductInfo{Product: product, FullVersion: version},
+			},
+		}
+	}
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	setDynamicClient(t, fake.NewSimpleDynamicClient(scheme,
+		&v1alpha1.ContentSourceBinding{
+			TypeMeta:         v1.TypeMeta{Kind: "ContentSourceBinding", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta:       v1.ObjectMeta{Name: "library", Namespace: "test"},
+			ContentSourceRef: v1alpha1.ContentSourceReference{Name: "library"},
+		},
+		&v1alpha1.ContentSource{
+			TypeMeta:   v1.TypeMeta{Kind: "ContentSource", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: "library"},
+			Spec:       v1alpha1.ContentSourceSpec{ProviderRef: v1alpha1.ContentProviderReference{Name: "library"}},
+		},
+		image("ubuntu-1804", "Ubuntu", "18.04.6", 0),
+		image("ubuntu-2004-old", "Ubuntu", "20.04.3", time.Hour),
+		image("ubuntu-2004-new", "Ubuntu", "20.04.3", 0),
+		image("ubuntu-2004-2", "Ubuntu", "20.04.2", 0),
+		image("centos-8", "CentOS", "8.4", 0),
+	))
+	setOptions(t, &VMOptions{Namespace: "test", tanzuDir: t.TempDir()})
+
+	tests := []struct {
+		name    string
+		os      string
+		want    string
+		wantErr bool
+	}{
+		{name: "newest-version", os: "ubuntu", want: "ubuntu-2004-new"},
+		{name: "version-prefix", os: "ubuntu:18.04", want: "ubuntu-1804"},
+		{name: "newest-created", os: "Ubuntu:20.04.3", want: "ubuntu-2004-new"},
+		{name: "other-distro", os: "centos:8", want: "centos-8"},
+		{name: "no-match", os: "ubuntu:22.04", wantErr: true},
+		{name: "no-distro", os: ":20.04", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveImage(ctx, tt.os)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveImage() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_compareVersions(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "20.04", b: "18.04", want: 1},
+		{a: "20.04.2", b: "20.04.10", want: -1},
+		{a: "20.04.1", b: "20.04", want: 1},
+		{a: "v1.2", b: "1.2", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"-"+tt.b, func(t *testing.T) {
+			got := compareVersions(tt.a, tt.b)
+			if (got > 0) != (tt.want > 0) || (got < 0) != (tt.want < 0) {
+				t.Errorf("compareVersions() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resolveClass(t *testing.T) {
+	ctx := context.Background()
+	class := func(name string, cpus int64, memory string) []runtime.Object {
+		return []runtime.Object{
+			&v1alpha1.VirtualMachineClassBinding{
+				TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineClassBinding", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+				ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "test"},
+				ClassRef:   v1alpha1.ClassReference{Name: name},
+			},
+			&v1alpha1.VirtualMachineClass{
+				TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineClass", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+				ObjectMeta: v1.ObjectMeta{Name: name},
+				Spec: v1alpha1.VirtualMachineClassSpec{
+					Hardware: v1alpha1.VirtualMachineClassHardware{Cpus: cpus, Memory: resource.MustParse(memory)},
+				},
+			},
+		}
+	}
+	var objects []runtime.Object
+	objects = append(objects, class("best-effort-large", 4, "16Gi")...)
+	objects = append(objects, class("best-effort-small", 2, "4Gi")...)
+	objects = append(objects, class("best-effort-medium", 2, "8Gi")...)
+	objects = append(objects, class("guaranteed-xlarge", 4, "32Gi")...)
+	objects = append(objects, &v1alpha1.VirtualMachineClass{
+		TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineClass", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+		ObjectMeta: v1.ObjectMeta{Name: "unbound-xxlarge"},
+		Spec: v1alpha1.VirtualMachineClassSpec{
+			Hardware: v1alpha1.VirtualMachineClassHardware{Cpus: 8, Memory: resource.MustParse("64Gi")},
+		},
+	})
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	setDynamicClient(t, fake.NewSimpleDynamicClient(scheme, objects...))
+	setOptions(t, &VMOptions{Namespace: "test"})
+
+	tests := []struct {
+		name    string
+		cpus    int
+		memory  string
+		want    string
+		wantErr bool
+	}{
+		{name: "cpus", cpus: 2, want: "best-effort-small"},
+		{name: "memory", memory: "6Gi", want: "best-effort-medium"},
+		{name: "cpus-and-memory", cpus: 3, memory: "20Gi", want: "guaranteed-xlarge"},
+		{name: "too-large", cpus: 8, wantErr: true},
+		{name: "invalid-memory", memory: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveClass(ctx, tt.cpus, tt.memory)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveClass() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveClass() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkIntentFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []string
+		wantErr string
+	}{
+		{name: "image", flags: []string{"--image", "ubuntu-2004"}},
+		{name: "intent", flags: []string{"--os", "ubuntu", "--cpus", "2"}},
+		{name: "image-and-os", flags: []string{"--image", "ubuntu-2004", "--os", "ubuntu"}, wantErr: "--image and --os can't be used together"},
+		{name: "class-and-memory", flags: []string{"--class", "best-effort-small", "--memory", "4Gi"}, wantErr: "--class and --memory can't be used together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{})
+			cmd := newCreateCmd(context.Background())
+			if err := cmd.ParseFlags(tt.flags); err != nil {
+				t.Fatal(err)
+			}
+			err := checkIntentFlags(cmd)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("checkIntentFlags() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
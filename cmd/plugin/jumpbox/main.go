@@ -70,6 +70,26 @@ func main() {
 		newPowerOnCmd(ctx),
 		newPowerOffCmd(ctx),
 		newDestroyCmd(ctx),
+		newDescribeCmd(ctx),
+		newRebuildCmd(ctx),
+		newUpdateCmd(ctx),
+		newAccessCmd(ctx),
+		newUserdataCmd(),
+		newTemplateCmd(),
+		newToolsCmd(ctx),
+		newDotfilesCmd(ctx),
+		newExpandDiskCmd(ctx),
+		newImagesCmd(ctx),
+		newClassesCmd(ctx),
+		newStorageClassesCmd(ctx),
+		newNetworksCmd(ctx),
+		newConfigCmd(),
+		newProfileCmd(),
+		newApplyCmd(ctx),
+		newDiffCmd(ctx),
+		newDeleteCmd(ctx),
+		newExportCmd(ctx),
+		newSchemaCmd(),
 	)
 	if err := p.Execute(); err != nil {
 		os.Exit(1)
@@ -80,11 +100,27 @@ func newCreateCmd(ctx context.Context) *cobra.Command {
 	createCmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create Jumpbox",
+		Args:  cobra.MaximumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			setup(args)
-			return buildUserdata()
+			err := checkDryRunFlags()
+			if err != nil {
+				return err
+			}
+			name, err := completeCreateFlags(ctx, cmd, args)
+			if err != nil {
+				return err
+			}
+			setup([]string{name})
+			return prepareCreate(ctx)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.preflightOnly {
+				fmt.Printf("Preflight checks passed for %s\n", options.Name)
+				return nil
+			}
+			if options.dryRun != dryRunNone {
+				return DryRun(ctx)
+			}
 			return CreateJumpBox(ctx)
 		}}
 
@@ -94,17 +130,64 @@ func newCreateCmd(ctx context.Context) *cobra.Command {
 	createCmd.Flags().StringVarP(&options.ClassName, "class", "c", "", "vm class")
 	createCmd.Flags().StringVarP(&options.NetworkType, "network-type", "", "", "Network type. `nsx-t` or `vsphere-distributed`")
 	createCmd.Flags().StringVarP(&options.NetworkName, "network-name", "", "", "Network name. required if network-type = `vsphere-distributed`")
-	createCmd.Flags().StringVarP(&options.User, "user", "u", "", "User to be created in VM")
-
-	_ = createCmd.MarkFlagRequired("namespace")
-	_ = createCmd.MarkFlagRequired("storage-class")
-	_ = createCmd.MarkFlagRequired("image")
-	_ = createCmd.MarkFlagRequired("class")
-	_ = createCmd.MarkFlagRequired("network-type")
+	createCmd.Flags().StringVarP(&options.User, "user", "u", defaultUser, "User to be created in VM")
+	createCmd.Flags().StringVarP(&options.Groups, "groups", "", defaultGroups, "Comma separated groups of the user")
+	createCmd.Flags().StringVarP(&options.Shell, "shell", "", defaultShell, "Login shell of the user")
+	createCmd.Flags().StringVarP(&options.Sudo, "sudo", "", sudoNoPasswd, "Sudo policy of the user. `none`, `password` or `nopasswd`")
+	addIntentFlags(createCmd)
+	addDiskFlags(createCmd)
+	addPortFlags(createCmd)
+	addNICFlags(createCmd)
+	createCmd.Flags().StringVarP(&options.Transport, "transport", "", transportAuto, "VM metadata transport. `auto`, `OvfEnv`, `ExtraConfig`, `CloudInit` or `Sysprep`. auto selects it from the image")
+	createCmd.Flags().StringVarP(&options.Bootstrap, "bootstrap", "", bootstrapAuto, "Resource holding the VM metadata. `auto`, `secret` or `configmap`. auto uses a Secret when the supervisor supports it")
+	addUserDataFlags(createCmd)
+	createCmd.Flags().BoolVarP(&options.preflightOnly, "preflight-only", "", false, "Run the preflight checks and exit without creating anything")
+	addDryRunFlags(createCmd)
+	createCmd.Flags().StringVarP(&options.profile, "profile", "", "", "Profile of the config file with the default create flags. Defaults to the current profile")
 
 	return createCmd
 }
 
+// prepareCreate validates the create options and renders the user data before anything is created. The access
+// roster is read unless the access users are set
+func prepareCreate(ctx context.Context) error {
+	err := validateUser()
+	if err != nil {
+		return err
+	}
+	err = resolvePorts()
+	if err != nil {
+		return err
+	}
+	err = resolveNICs()
+	if err != nil {
+		return err
+	}
+	err = preflight(ctx)
+	if err != nil {
+		return err
+	}
+	if options.AccessUsers == nil {
+		options.AccessUsers, err = getAccessUsers(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	err = resolveTransport(ctx)
+	if err != nil {
+		return err
+	}
+	err = buildUserdata()
+	if err != nil {
+		return err
+	}
+	err = resolveBootstrap(ctx)
+	if err != nil {
+		return err
+	}
+	return validateOfflineTools()
+}
+
 func newSSHCmd(ctx context.Context) *cobra.Command {
 	sshCmd := &cobra.Command{
 		Use:   "ssh",
@@ -169,6 +252,7 @@ func newDestroyCmd(ctx context.Context) *cobra.Command {
 		}}
 	destroyCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
 	_ = destroyCmd.MarkFlagRequired("namespace")
+	destroyCmd.Flags().BoolVarP(&options.keepVolumes, "keep-volume", "", false, "Keep the persistent volumes of the workspace and the extra volumes")
 
 	return destroyCmd
 }
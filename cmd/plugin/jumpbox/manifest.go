@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"io"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// manifestAPIVersion and manifestKind identify a jumpbox manifest
+const (
+	manifestAPIVersion = "jumpbox.tanzu.vmware.com/v1alpha1"
+	manifestKind       = "Jumpbox"
+)
+
+//go:embed manifests/jumpbox.schema.json
+var manifestSchema []byte
+
+// JumpboxManifest is the declarative definition of a jumpbox, kept in Git and applied with `tanzu jumpbox apply -f`
+type JumpboxManifest struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Metadata   ManifestMetadata `json:"metadata"`
+	Spec       ManifestSpec     `json:"spec"`
+
+	// dir is the manifest directory, the relative paths of the manifest are relative to
+	dir string
+}
+
+type ManifestMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// ManifestSpec are the create options of the jumpbox. Relative paths are relative to the manifest file
+type ManifestSpec struct {
+	// Image or OS select the VM image, like --image and --os
+	Image string `json:"image,omitempty"`
+	OS    string `json:"os,omitempty"`
+	// Class, or CPUs and Memory, select the VM class, like --class, --cpus and --memory
+	Class        string           `json:"class,omitempty"`
+	CPUs         int              `json:"cpus,omitempty"`
+	Memory       string           `json:"memory,omitempty"`
+	StorageClass string           `json:"storageClass"`
+	Network      ManifestNetwork  `json:"network"`
+	Disk         *DiskSettings    `json:"disk,omitempty"`
+	Volumes      []VolumeSettings `json:"volumes,omitempty"`
+	User         *ManifestUser    `json:"user,omitempty"`
+	// AccessUsers is the access roster. The roster is left as is when it is not set
+	AccessUsers   []AccessUser           `json:"accessUsers,omitempty"`
+	Template      string                 `json:"template,omitempty"`
+	UserData      string                 `json:"userData,omitempty"`
+	MergeUserData bool                   `json:"mergeUserData,omitempty"`
+	Values        map[string]interface{} `json:"values,omitempty"`
+	// SecretValues is the path of a yaml file with the secret template values, kept out of the manifest
+	SecretValues string        `json:"secretValues,omitempty"`
+	Tools        []string      `json:"tools,omitempty"`
+	OfflineTools string        `json:"offlineTools,omitempty"`
+	AptMirror    string        `json:"aptMirror,omitempty"`
+	Dotfiles     string        `json:"dotfiles,omitempty"`
+	Transport    string        `json:"transport,omitempty"`
+	Bootstrap    string        `json:"bootstrap,omitempty"`
+	Ports        []ServicePort `json:"ports,omitempty"`
+}
+
+// ManifestNetwork is the VM network and the proxy, CA, DNS and NTP settings of the guest
+type ManifestNetwork struct {
+	Type string `json:"type,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Interfaces are the network interfaces, instead of Type and Name
+	Interfaces []NetworkInterface `json:"interfaces,omitempty"`
+	NetworkSettings
+}
+
+// ManifestUser is the user provisioned in the jumpbox
+type ManifestUser struct {
+	Name   string `json:"name,omitempty"`
+	Groups string `json:"groups,omitempty"`
+	Shell  string `json:"shell,omitempty"`
+	Sudo   string `json:"sudo,omitempty"`
+}
+
+// loadManifest reads and validates a manifest file, or stdin when path is -
+func loadManifest(path string) (*JumpboxManifest, error) {
+	var data []byte
+	var err error
+	dir := "."
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+		dir = filepath.Dir(path)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading manifest")
+	}
+	return parseManifest(data, dir)
+}
+
+// parseManifest parses and validates a manifest. Its relative paths are read relative to dir
+func parseManifest(data []byte, dir string) (*JumpboxManifest, error) {
+	manifest := &JumpboxManifest{}
+	err := yaml.UnmarshalStrict(data, manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing manifest")
+	}
+	err = manifest.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.dir = dir
+	return manifest, nil
+}
+
+func (m *JumpboxManifest) validate() error {
+	if m.APIVersion != manifestAPIVersion {
+		return errors.Errorf("unsupported manifest apiVersion %q, expected %s", m.APIVersion, manifestAPIVersion)
+	}
+	if m.Kind != manifestKind {
+		return errors.Errorf("unsupported manifest kind %q, expected %s", m.Kind, manifestKind)
+	}
+	if m.Metadata.Name == "" || m.Metadata.Namespace == "" {
+		return errors.New("manifest metadata.name and metadata.namespace are required")
+	}
+	spec := m.Spec
+	switch {
+	case spec.Image == "" && spec.OS == "":
+		return errors.New("manifest spec.image or spec.os is required")
+	case spec.Image != "" && spec.OS != "":
+		return errors.New("manifest spec.image and spec.os can't be used together")
+	case spec.Class == "" && spec.CPUs == 0 && spec.Memory == "":
+		return errors.New("manifest spec.class, or spec.cpus and spec.memory, is required")
+	case spec.Class != "" && (spec.CPUs > 0 || spec.Memory != ""):
+		return errors.New("manifest spec.class can't be used together with spec.cpus and spec.memory")
+	case spec.StorageClass == "":
+		return errors.New("manifest spec.storageClass is required")
+	case spec.Network.Type == "" && len(spec.Network.Interfaces) == 0:
+		return errors.New("manifest spec.network.type or spec.network.interfaces is required")
+	case spec.Network.Type != "" && len(spec.Network.Interfaces) > 0:
+		return errors.New("manifest spec.network.type and spec.network.interfaces can't be used together")
+	}
+	loginUser := defaultUser
+	if spec.User != nil && spec.User.Name != "" {
+		loginUser = spec.User.Name
+	}
+	cloudUser := manifestCloudUser(spec)
+	for i, user := range spec.AccessUsers {
+		err := checkAccessUser(user.Name, loginUser, cloudUser)
+		if err != nil {
+			return errors.WithMessage(err, "invalid access user")
+		}
+		keys, err := parseAuthorizedKeys([]byte(strings.Join(user.Keys, "\n")))
+		if err != nil {
+			return errors.WithMessage(err, "invalid keys of access user "+user.Name)
+		}
+		m.Spec.AccessUsers[i].Keys = keys
+	}
+	return nil
+}
+
+// manifestCloudUser guesses the image default user from the image name or os, without reading the image
+func manifestCloudUser(spec ManifestSpec) string {
+	config, err := loadConfig()
+	if err != nil {
+		config = &PluginConfig{}
+	}
+	image := &v1alpha1.VirtualMachineImage{ObjectMeta: v1.ObjectMeta{Name: spec.Image + spec.OS}}
+	return imageCloudUser(image, config)
+}
+
+// applyTo sets the create options from the manifest, with the create flag defaults for the settings it doesn't set
+func (m *JumpboxManifest) applyTo(o *VMOptions) {
+	spec := m.Spec
+	o.baseDir = m.dir
+	o.Namespace = m.Metadata.Namespace
+	o.ImageName = spec.Image
+	o.OS = spec.OS
+	o.ClassName = spec.Class
+	o.CPUs = spec.CPUs
+	o.Memory = spec.Memory
+	o.StorageClassName = spec.StorageClass
+	o.NetworkType = spec.Network.Type
+	o.NetworkName = spec.Network.Name
+	o.NICs = spec.Network.Interfaces
+	o.Network = spec.Network.NetworkSettings
+
+	o.Disk = DiskSettings{Size: defaultDiskSize, FSType: defaultDiskFSType}
+	if spec.Disk != nil {
+		if spec.Disk.Size != "" {
+			o.Disk.Size = spec.Disk.Size
+		}
+		if spec.Disk.FSType != "" {
+			o.Disk.FSType = spec.Disk.FSType
+		}
+		o.Disk.MountPath = spec.Disk.MountPath
+	}
+	o.Volumes = nil
+	for _, volume := range spec.Volumes {
+		if volume.StorageClass == "" {
+			volume.StorageClass = spec.StorageClass
+		}
+		if volume.FSType == "" {
+			volume.FSType = defaultDiskFSType
+		}
+		o.Volumes = append(o.Volumes, volume)
+	}
+
+	user := ManifestUser{Name: defaultUser, Groups: defaultGroups, Shell: defaultShell, Sudo: sudoNoPasswd}
+	if spec.User != nil {
+		if spec.User.Name != "" {
+			user.Name = spec.User.Name
+		}
+		if spec.User.Groups != "" {
+			user.Groups = spec.User.Groups
+		}
+		if spec.User.Shell != "" {
+			user.Shell = spec.User.Shell
+		}
+		if spec.User.Sudo != "" {
+			user.Sudo = spec.User.Sudo
+		}
+	}
+	o.User = user.Name
+	o.Groups = user.Groups
+	o.Shell = user.Shell
+	o.Sudo = user.Sudo
+	o.AccessUsers = spec.AccessUsers
+
+	o.Template = spec.Template
+	o.userDataPath = spec.UserData
+	o.mergeUserData = spec.MergeUserData
+	o.Values = spec.Values
+	o.secretValuesPath = spec.SecretValues
+	o.Tools = spec.Tools
+	o.offlineToolsPath = spec.OfflineTools
+	o.AptMirror = spec.AptMirror
+	o.dotfilesPath = spec.Dotfiles
+	o.Transport = spec.Transport
+	if o.Transport == "" {
+		o.Transport = transportAuto
+	}
+	o.Bootstrap = spec.Bootstrap
+	if o.Bootstrap == "" {
+		o.Bootstrap = bootstrapAuto
+	}
+	o.Ports = append([]ServicePort(nil), spec.Ports...)
+}
+
+// exportManifest builds the manifest of a live jumpbox from its spec and access roster. The image and class are the
+// names resolved on create, so applying the manifest again doesn't pick newer ones
+func exportManifest(spec *JumpboxSpec, users []AccessUser) *JumpboxManifest {
+	manifest := &JumpboxManifest{
+		APIVersion: manifestAPIVersion,
+		Kind:       manifestKind,
+		Metadata:   ManifestMetadata{Name: options.Name, Namespace: options.Namespace},
+		Spec: ManifestSpec{
+			Image:        spec.ImageName,
+			Class:        spec.ClassName,
+			StorageClass: spec.StorageClassName,
+			Network:      ManifestNetwork{Type: spec.NetworkType, Name: spec.NetworkName},
+			Disk:         spec.Disk,
+			Volumes:      spec.Volumes,
+			User: &ManifestUser{
+				Name:   spec.User,
+				Groups: spec.Groups,
+				Shell:  spec.Shell,
+				Sudo:   spec.Sudo,
+			},
+			Template:      spec.Template,
+			UserData:      spec.UserDataFile,
+			MergeUserData: spec.MergeUserData,
+			Values:        spec.Values,
+			Tools:         spec.Tools,
+			OfflineTools:  spec.OfflineToolsDir,
+			AptMirror:     spec.AptMirror,
+			Dotfiles:      spec.DotfilesDir,
+			Transport:     spec.Transport,
+			Bootstrap:     spec.Bootstrap,
+			Ports:         spec.Ports,
+		},
+	}
+	if spec.Network != nil {
+		manifest.Spec.Network.NetworkSettings = *spec.Network
+	}
+	// the network type and name are the ones of the first interface
+	if len(spec.NICs) > 0 {
+		manifest.Spec.Network.Type = ""
+		manifest.Spec.Network.Name = ""
+		manifest.Spec.Network.Interfaces = spec.NICs
+	}
+	if len(users) > 0 {
+		manifest.Spec.AccessUsers = users
+	}
+	return manifest
+}
+
+func newApplyCmd(ctx context.Context) *cobra.Command {
+	var file string
+	applyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Create or update a Jumpbox to match a manifest",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, err := loadManifest(file)
+			if err != nil {
+				return err
+			}
+			return Apply(ctx, manifest)
+		}}
+	applyCmd.Flags().StringVarP(&file, "filename", "f", "", "Path to the Jumpbox manifest, - for stdin")
+	_ = applyCmd.MarkFlagRequired("filename")
+
+	return applyCmd
+}
+
+func newDeleteCmd(ctx context.Context) *cobra.Command {
+	var file string
+	deleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Destroy the Jumpbox of a manifest",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, err := loadManifest(file)
+			if err != nil {
+				return err
+			}
+			options.Namespace = manifest.Metadata.Namespace
+			setup([]string{manifest.Metadata.Name})
+			return Destroy(ctx)
+		}}
+	deleteCmd.Flags().StringVarP(&file, "filename", "f", "", "Path to the Jumpbox manifest, - for stdin")
+	deleteCmd.Flags().BoolVarP(&options.keepVolumes, "keep-volume", "", false, "Keep the persistent volumes of the workspace and the extra volumes")
+	_ = deleteCmd.MarkFlagRequired("filename")
+
+	return deleteCmd
+}
+
+func newExportCmd(ctx context.Context) *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print the manifest of a live Jumpbox",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Export(ctx)
+		}}
+	exportCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	_ = exportCmd.MarkFlagRequired("namespace")
+
+	return exportCmd
+}
+
+func newSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the Jumpbox manifest",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := os.Stdout.Write(manifestSchema)
+			return err
+		}}
+}
+
+// Export prints the manifest of the jumpbox
+func Export(ctx context.Context) error {
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return err
+	}
+	users, err := getAccessUsers(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(exportManifest(spec, users))
+	if err != nil {
+		return errors.Wrap(err, "error marshaling manifest")
+	}
+	fmt.Print(string(data))
+	if len(spec.SecretKeys) > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "jumpbox %s uses the secret values %s. Set spec.secretValues to a file with them to apply the manifest\n",
+			options.Name, strings.Join(spec.SecretKeys, ", "))
+	}
+	return nil
+}
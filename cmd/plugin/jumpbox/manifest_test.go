@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testManifest = `apiVersion: jumpbox.tanzu.vmware.com/v1alpha1
+kind: Jumpbox
+metadata:
+  name: jumpbox-1
+  namespace: dev
+spec:
+  os: ubuntu:20.04
+  class: best-effort-small
+  storageClass: gold
+  network:
+    type: nsx-t
+    httpProxy: http://proxy:3128
+    caCertFiles: [certs/ca.pem]
+  disk:
+    size: 64Gi
+  volumes:
+  - name: scratch
+    size: 200Gi
+    mountPath: /scratch
+  user:
+    name: alice
+  userData: userdata.yaml
+  ports:
+  - name: http
+    port: 80
+`
+
+func Test_parseManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(string) string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			edit: func(m string) string { return m },
+		},
+		{
+			name:    "kind",
+			edit:    func(m string) string { return strings.Replace(m, "kind: Jumpbox", "kind: VirtualMachine", 1) },
+			wantErr: `unsupported manifest kind "VirtualMachine", expected Jumpbox`,
+		},
+		{
+			name: "image-and-os",
+			edit: func(m string) string {
+				return strings.Replace(m, "  os: ubuntu:20.04\n", "  os: ubuntu:20.04\n  image: ubuntu-2004\n", 1)
+			},
+			wantErr: "manifest spec.image and spec.os can't be used together",
+		},
+		{
+			name:    "no-class",
+			edit:    func(m string) string { return strings.Replace(m, "  class: best-effort-small\n", "", 1) },
+			wantErr: "manifest spec.class, or spec.cpus and spec.memory, is required",
+		},
+		{
+			name:    "login-user-access",
+			edit:    func(m string) string { return m + "  accessUsers:\n  - name: alice\n    keys: []\n" },
+			wantErr: "invalid access user: user alice is reserved",
+		},
+		{
+			name:    "cloud-user-access",
+			edit:    func(m string) string { return m + "  accessUsers:\n  - name: ubuntu\n    keys: []\n" },
+			wantErr: "invalid access user: user ubuntu is reserved",
+		},
+		{
+			name:    "unknown-field",
+			edit:    func(m string) string { return strings.Replace(m, "  storageClass: gold", "  storage: gold", 1) },
+			wantErr: "error parsing manifest",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseManifest([]byte(tt.edit(testManifest)), "/git/jumpboxes")
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("parseManifest() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseManifest() error = %v", err)
+			}
+			// the paths are kept relative to the manifest and read from its directory
+			if got.Spec.UserData != "userdata.yaml" || got.Spec.Network.CACertFiles[0] != "certs/ca.pem" {
+				t.Errorf("parseManifest() paths = %+v, want the manifest paths", got.Spec)
+			}
+			setOptions(t, &VMOptions{})
+			got.applyTo(options)
+			if localPath(options.userDataPath) != "/git/jumpboxes/userdata.yaml" {
+				t.Errorf("parseManifest() user data read from %s", localPath(options.userDataPath))
+			}
+			if got.Spec.Network.HTTPProxy != "http://proxy:3128" {
+				t.Errorf("parseManifest() network settings = %+v", got.Spec.Network)
+			}
+		})
+	}
+}
+
+func Test_manifestApplyTo(t *testing.T) {
+	manifest, err := parseManifest([]byte(testManifest), "/git/jumpboxes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &VMOptions{}
+	manifest.applyTo(got)
+
+	if got.Namespace != "dev" || got.OS != "ubuntu:20.04" || got.ClassName != "best-effort-small" || got.NetworkType != networkTypeNSXT {
+		t.Errorf("applyTo() got %+v", got)
+	}
+	if got.User != "alice" || got.Groups != "sudo" || got.Sudo != sudoNoPasswd || got.Transport != transportAuto || got.Bootstrap != bootstrapAuto {
+		t.Errorf("applyTo() defaults got %+v", got)
+	}
+	wantDisk := DiskSettings{Size: "64Gi", FSType: defaultDiskFSType}
+	if got.Disk != wantDisk {
+		t.Errorf("applyTo() disk = %+v, want %+v", got.Disk, wantDisk)
+	}
+	wantVolumes := []VolumeSettings{{Name: "scratch", Size: "200Gi", StorageClass: "gold", MountPath: "/scratch", FSType: defaultDiskFSType}}
+	if !reflect.DeepEqual(got.Volumes, wantVolumes) {
+		t.Errorf("applyTo() volumes = %+v, want %+v", got.Volumes, wantVolumes)
+	}
+	if got.AccessUsers != nil {
+		t.Errorf("applyTo() access users = %+v, want the roster left as is", got.AccessUsers)
+	}
+}
+
+func Test_exportManifest(t *testing.T) {
+	setOptions(t, &VMOptions{Name: "jumpbox-1", Namespace: "dev"})
+	spec := &JumpboxSpec{
+		Version:          specVersion,
+		ImageName:        "ubuntu-2004",
+		ClassName:        "best-effort-small",
+		StorageClassName: "gold",
+		NetworkType:      networkTypeVDS,
+		NetworkName:      "workload",
+		User:             "alice",
+		Groups:           "sudo",
+		Shell:            "/bin/bash",
+		Sudo:             sudoNoPasswd,
+		Template:         defaultTemplate,
+		Tools:            []string{"kubectl"},
+		Transport:        transportCloudInit,
+		Bootstrap:        bootstrapSecret,
+		Network:          &NetworkSettings{HTTPProxy: "http://proxy:3128"},
+		Disk:             &DiskSettings{Size: "64Gi", FSType: "ext4", MountPath: "/home/alice"},
+		OS:               "ubuntu",
+		Ports:            []ServicePort{{Name: "http", Port: 80, TargetPort: 8080, Protocol: "TCP"}},
+	}
+	manifest := exportManifest(spec, nil)
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseManifest(data, ".")
+	if err != nil {
+		t.Fatalf("parseManifest() of the export error = %v", err)
+	}
+
+	got := &VMOptions{}
+	parsed.applyTo(got)
+	if got.ImageName != spec.ImageName || got.OS != "" || got.ClassName != spec.ClassName || got.NetworkName != spec.NetworkName ||
+		got.Network.HTTPProxy != spec.Network.HTTPProxy || got.Disk != *spec.Disk || got.Transport != spec.Transport ||
+		!reflect.DeepEqual(got.Ports, spec.Ports) || !reflect.DeepEqual(got.Tools, spec.Tools) {
+		t.Errorf("export round trip got %+v", got)
+	}
+}
+
+// Test_manifestSchema checks the published JSON Schema has the fields of the manifest types
+func Test_manifestSchema(t *testing.T) {
+	schema := map[string]interface{}{}
+	err := json.Unmarshal(manifestSchema, &schema)
+	if err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	properties := func(path ...string) []string {
+		var node interface{} = schema
+		for _, p := range path {
+			node = node.(map[string]interface{})[p]
+		}
+		var names []string
+		for name := range node.(map[string]interface{}) {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	fields := func(value interface{}) []string {
+		data, _ := json.Marshal(value)
+		m := map[string]interface{}{}
+		_ = json.Unmarshal(data, &m)
+		var names []string
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	full := ManifestSpec{
+		Image: "a", OS: "a", Class: "a", CPUs: 1, Memory: "a", StorageClass: "a", Disk: &DiskSettings{}, Volumes: []VolumeSettings{{}},
+		User: &ManifestUser{}, AccessUsers: []AccessUser{{}}, Template: "a", UserData: "a", MergeUserData: true,
+		Values: map[string]interface{}{"a": "a"}, SecretValues: "a", Tools: []string{"a"}, OfflineTools: "a", AptMirror: "a",
+		Dotfiles: "a", Transport: "a", Bootstrap: "a", Ports: []ServicePort{{}},
+	}
+	if got, want := properties("properties", "spec", "properties"), fields(full); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema spec properties = %v, want %v", got, want)
+	}
+	network := ManifestNetwork{Type: "a", Name: "a", Interfaces: []NetworkInterface{{}}, NetworkSettings: NetworkSettings{
+		HTTPProxy: "a", HTTPSProxy: "a", NoProxy: "a", CACertFiles: []string{"a"}, DNSServers: []string{"a"}, SearchDomains: []string{"a"}, NTPServers: []string{"a"},
+	}}
+	if got, want := properties("properties", "spec", "properties", "network", "properties"), fields(network); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema network properties = %v, want %v", got, want)
+	}
+	disk := DiskSettings{Size: "a", FSType: "a", MountPath: "a"}
+	if got, want := properties("properties", "spec", "properties", "disk", "properties"), fields(disk); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema disk properties = %v, want %v", got, want)
+	}
+	if got, want := properties("properties", "spec", "properties", "volumes", "items", "properties"), fields(VolumeSettings{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema volume properties = %v, want %v", got, want)
+	}
+	user := ManifestUser{Name: "a", Groups: "a", Shell: "a", Sudo: "a"}
+	nic := NetworkInterface{Type: "a", Name: "a", IP: "a", Gateway: "a"}
+	if got, want := properties("definitions", "nic", "properties"), fields(nic); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema nic properties = %v, want %v", got, want)
+	}
+	if got, want := properties("properties", "spec", "properties", "user", "properties"), fields(user); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema user properties = %v, want %v", got, want)
+	}
+	port := ServicePort{Name: "a", Port: 1, TargetPort: 1, Protocol: "a"}
+	if got, want := properties("properties", "spec", "properties", "ports", "items", "properties"), fields(port); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema port properties = %v, want %v", got, want)
+	}
+}
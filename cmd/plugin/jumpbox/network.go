@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// NetworkSettings are the site network settings of the jumpbox, like a corporate proxy and CA. Create flags override
+// the defaults of the `network` section in ~/.tanzu/jumpbox/config.yaml
+type NetworkSettings struct {
+	HTTPProxy  string `json:"httpProxy,omitempty"`
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	NoProxy    string `json:"noProxy,omitempty"`
+	// CACertFiles are the paths of PEM files with the CA certificates trusted by the jumpbox
+	CACertFiles   []string `json:"caCertFiles,omitempty"`
+	DNSServers    []string `json:"dnsServers,omitempty"`
+	SearchDomains []string `json:"searchDomains,omitempty"`
+	NTPServers    []string `json:"ntpServers,omitempty"`
+}
+
+// resolvedConfPath is the systemd-resolved drop-in with the DNS settings
+const resolvedConfPath = "/etc/systemd/resolved.conf.d/jumpbox.conf"
+
+func addNetworkFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&options.Network.HTTPProxy, "http-proxy", "", "", "HTTP proxy url")
+	cmd.Flags().StringVarP(&options.Network.HTTPSProxy, "https-proxy", "", "", "HTTPS proxy url")
+	cmd.Flags().StringVarP(&options.Network.NoProxy, "no-proxy", "", "", "Comma separated hosts and domains not using the proxy")
+	cmd.Flags().StringArrayVarP(&options.Network.CACertFiles, "ca-cert", "", nil, "Path to a PEM file with CA certificates to trust. Can be repeated")
+	cmd.Flags().StringSliceVarP(&options.Network.DNSServers, "dns", "", nil, "Comma separated DNS servers")
+	cmd.Flags().StringSliceVarP(&options.Network.SearchDomains, "search-domain", "", nil, "Comma separated DNS search domains")
+	cmd.Flags().StringSliceVarP(&options.Network.NTPServers, "ntp", "", nil, "Comma separated NTP servers")
+}
+
+// applyDefaults sets the settings not given from defaults
+func (n *NetworkSettings) applyDefaults(defaults NetworkSettings) {
+	setDefault := func(value *string, def string) {
+		if *value == "" {
+			*value = def
+		}
+	}
+	setDefaultList := func(value *[]string, def []string) {
+		if len(*value) == 0 {
+			*value = def
+		}
+	}
+	setDefault(&n.HTTPProxy, defaults.HTTPProxy)
+	setDefault(&n.HTTPSProxy, defaults.HTTPSProxy)
+	setDefault(&n.NoProxy, defaults.NoProxy)
+	setDefaultList(&n.CACertFiles, defaults.CACertFiles)
+	setDefaultList(&n.DNSServers, defaults.DNSServers)
+	setDefaultList(&n.SearchDomains, defaults.SearchDomains)
+	setDefaultList(&n.NTPServers, defaults.NTPServers)
+}
+
+func (n *NetworkSettings) empty() bool {
+	return n.HTTPProxy == "" && n.HTTPSProxy == "" && n.NoProxy == "" && len(n.CACertFiles) == 0 &&
+		len(n.DNSServers) == 0 && len(n.SearchDomains) == 0 && len(n.NTPServers) == 0
+}
+
+// networkCloudConfig is the cloud-config, merged over the template, with the network settings. The proxy is set for
+// apt and in /etc/environment, CA certificates with ca_certs, DNS with a systemd-resolved drop-in written on boot,
+// before packages are installed, and NTP with the ntp module
+func networkCloudConfig(n *NetworkSettings, caCerts []string) ([]byte, error) {
+	config := map[string]interface{}{}
+
+	var environment []string
+	apt := map[string]interface{}{}
+	if n.HTTPProxy != "" {
+		environment = append(environment, "http_proxy="+n.HTTPProxy, "HTTP_PROXY="+n.HTTPProxy)
+		apt["http_proxy"] = n.HTTPProxy
+	}
+	if n.HTTPSProxy != "" {
+		environment = append(environment, "https_proxy="+n.HTTPSProxy, "HTTPS_PROXY="+n.HTTPSProxy)
+		apt["https_proxy"] = n.HTTPSProxy
+	}
+	if n.NoProxy != "" {
+		environment = append(environment, "no_proxy="+n.NoProxy, "NO_PROXY="+n.NoProxy)
+	}
+	if len(apt) > 0 {
+		config["apt"] = apt
+	}
+	if len(environment) > 0 {
+		config["write_files"] = []interface{}{
+			map[string]interface{}{
+				"path":    "/etc/environment",
+				"content": strings.Join(environment, "\n") + "\n",
+				"append":  true,
+			},
+		}
+	}
+
+	if len(caCerts) > 0 {
+		trusted := make([]interface{}, 0, len(caCerts))
+		for _, cert := range caCerts {
+			trusted = append(trusted, cert)
+		}
+		config["ca_certs"] = map[string]interface{}{"trusted": trusted}
+	}
+
+	if len(n.DNSServers) > 0 || len(n.SearchDomains) > 0 {
+		resolved := "[Resolve]\n"
+		if len(n.DNSServers) > 0 {
+			resolved += "DNS=" + strings.Join(n.DNSServers, " ") + "\n"
+		}
+		if len(n.SearchDomains) > 0 {
+			resolved += "Domains=" + strings.Join(n.SearchDomains, " ") + "\n"
+		}
+		config["bootcmd"] = []interface{}{
+			"mkdir -p " + filepath.Dir(resolvedConfPath),
+			fmt.Sprintf("printf %s > %s", shellQuote(resolved), resolvedConfPath),
+			"systemctl try-restart systemd-resolved || true",
+		}
+	}
+
+	if len(n.NTPServers) > 0 {
+		servers := make([]interface{}, 0, len(n.NTPServers))
+		for _, s := range n.NTPServers {
+			servers = append(servers, s)
+		}
+		config["ntp"] = map[string]interface{}{"enabled": true, "servers": servers}
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling network cloud-config")
+	}
+	return append([]byte(cloudConfigHeader+"\n"), data...), nil
+}
+
+// resolveNetworkSettings fills the network settings from the config file defaults and reads the CA certificates,
+// unless they come from the spec
+func resolveNetworkSettings() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	options.Network.applyDefaults(config.Network)
+	if options.caCerts != nil {
+		return nil
+	}
+	options.caCerts, err = readCACerts(options.Network.CACertFiles)
+	return err
+}
+
+// readCACerts reads the PEM files of the CA certificates
+func readCACerts(files []string) ([]string, error) {
+	var certs []string
+	for _, file := range files {
+		data, err := os.ReadFile(localPath(file))
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading CA certificate")
+		}
+		if !strings.Contains(string(data), "-----BEGIN CERTIFICATE-----") {
+			return nil, errors.Errorf("%s is not a PEM certificate", file)
+		}
+		certs = append(certs, string(data))
+	}
+	return certs, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCACert = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+
+func Test_networkCloudConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings NetworkSettings
+		caCerts  []string
+		want     []string
+	}{
+		{
+			name:     "proxy",
+			settings: NetworkSettings{HTTPProxy: "http://proxy:3128", NoProxy: "10.0.0.0/8,.corp"},
+			want:     []string{"http_proxy: http://proxy:3128", "HTTP_PROXY=http://proxy:3128", "no_proxy=10.0.0.0/8,.corp", "path: /etc/environment"},
+		},
+		{
+			name:     "ca-dns-ntp",
+			settings: NetworkSettings{CACertFiles: []string{"ca.pem"}, DNSServers: []string{"10.0.0.2"}, SearchDomains: []string{"corp.local"}, NTPServers: []string{"ntp.corp.local"}},
+			caCerts:  []string{testCACert},
+			want:     []string{"ca_certs:", "BEGIN CERTIFICATE", "DNS=10.0.0.2", "Domains=corp.local", "ntp.corp.local"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := networkCloudConfig(&tt.settings, tt.caCerts)
+			if err != nil {
+				t.Fatalf("networkCloudConfig() error = %v", err)
+			}
+			if err := validateUserData(doc); err != nil {
+				t.Errorf("networkCloudConfig() is invalid: %v\n%s", err, doc)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(doc), want) {
+					t.Errorf("networkCloudConfig() missing %q\n%s", want, doc)
+				}
+			}
+		})
+	}
+}
+
+func Test_resolveNetworkSettings(t *testing.T) {
+	setOptions(t, &VMOptions{
+		tanzuDir: t.TempDir(),
+		Network:  NetworkSettings{HTTPProxy: "http://flag:3128"},
+	})
+	config := "network:\n  httpProxy: http://config:3128\n  ntpServers:\n    - ntp.corp.local\n"
+	if err := os.WriteFile(configPath(), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolveNetworkSettings(); err != nil {
+		t.Fatalf("resolveNetworkSettings() error = %v", err)
+	}
+	want := NetworkSettings{HTTPProxy: "http://flag:3128", NTPServers: []string{"ntp.corp.local"}}
+	if !reflect.DeepEqual(options.Network, want) {
+		t.Errorf("resolveNetworkSettings() got %+v, want %+v", options.Network, want)
+	}
+}
+
+func Test_readCACerts(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ca.pem"), []byte(testCACert), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ca.txt"), []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		files   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "relative-to-manifest", files: []string{"ca.pem"}, want: []string{testCACert}},
+		{name: "missing", files: []string{"missing.pem"}, wantErr: true},
+		{name: "not-pem", files: []string{"ca.txt"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{baseDir: dir})
+			got, err := readCACerts(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readCACerts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readCACerts() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"net"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// NetworkInterface is a network interface of the jumpbox. IP and Gateway set a static address, DHCP is used otherwise
+type NetworkInterface struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	// IP is the static address of the interface, in CIDR notation
+	IP      string `json:"ip,omitempty"`
+	Gateway string `json:"gateway,omitempty"`
+}
+
+// netplanConfigPath is the netplan config of the interfaces. It replaces the cloud-init one
+const netplanConfigPath = "/etc/netplan/60-jumpbox.yaml"
+
+// cloudInitNetplanPath is the netplan config cloud-init writes, DHCP on the first interface
+const cloudInitNetplanPath = "/etc/netplan/50-cloud-init.yaml"
+
+// cloudInitNetworkDisabledPath disables the cloud-init network config, so it doesn't write its netplan config again
+const cloudInitNetworkDisabledPath = "/etc/cloud/cloud.cfg.d/99-jumpbox-network.cfg"
+
+// nicConfigScript writes the network config with the guest names of the interfaces, taken in PCI order, the order of
+// the VM network interfaces, and applies it. The config has every interface of the VM, so the cloud-init network config
+// is disabled and its netplan config removed, otherwise its DHCP config of the first interface would be merged with a
+// static one. It runs on every boot and is a no-op when the config didn't change.
+// The config can't be a cloud-init network config: vSphere assigns the MAC addresses when the VM is created, and
+// cloud-init can only match interfaces by MAC address or guest name, not by order. The placeholders are the whole
+// quoted keys of the interfaces, so @NIC1@ doesn't match in @NIC10@. Preflight checks the image has netplan, the script
+// fails without it
+const nicConfigScript = `set -eu
+conf=%[1]s config=%[2]s cloud_init_conf=%[3]s cloud_init_disabled=%[4]s
+if ! command -v netplan >/dev/null; then
+  echo "netplan not found, the jumpbox network config can't be applied" >&2
+  exit 1
+fi
+printf '%%s' "$config" > "$conf.tmp"
+i=0
+for dev in $(for d in /sys/class/net/*; do [ -e "$d/device" ] && echo "$(readlink -f "$d/device") ${d##*/}"; done | sort | cut -d' ' -f2); do
+  sed -i "s/^\( *\)'@NIC$i@':/\1$dev:/" "$conf.tmp"
+  i=$((i+1))
+done
+if grep -q '@NIC' "$conf.tmp"; then
+  echo "the jumpbox has $i network interfaces, fewer than the network config" >&2
+  rm -f "$conf.tmp"
+  exit 1
+fi
+chmod 600 "$conf.tmp"
+if cmp -s "$conf.tmp" "$conf" && [ ! -e "$cloud_init_conf" ]; then
+  rm -f "$conf.tmp"
+  exit 0
+fi
+printf 'network: {config: disabled}\n' > "$cloud_init_disabled"
+rm -f "$cloud_init_conf"
+mv "$conf.tmp" "$conf"
+netplan apply
+`
+
+func addNICFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&options.nicFlags, "nic", "", nil, "Network interface, `type=<t>,name=<n>[,ip=<cidr>,gateway=<gw>]`. Can be repeated, the first one is the primary interface. Overrides --network-type and --network-name")
+}
+
+func parseNIC(flag string) (NetworkInterface, error) {
+	nic := NetworkInterface{}
+	for _, field := range strings.Split(flag, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nic, errors.Errorf("invalid nic %q, expected key=value fields", flag)
+		}
+		switch kv[0] {
+		case "type":
+			nic.Type = kv[1]
+		case "name":
+			nic.Name = kv[1]
+		case "ip":
+			nic.IP = kv[1]
+		case "gateway":
+			nic.Gateway = kv[1]
+		default:
+			return nic, errors.Errorf("invalid nic %q, unknown field %q", flag, kv[0])
+		}
+	}
+	return nic, nil
+}
+
+// resolveNICs adds the --nic flags to the network interfaces and validates them. The first interface sets the network
+// type and name, so the commands showing a single network keep working
+func resolveNICs() error {
+	if len(options.nicFlags) > 0 {
+		options.NICs = nil
+	}
+	for _, flag := range options.nicFlags {
+		nic, err := parseNIC(flag)
+		if err != nil {
+			return err
+		}
+		options.NICs = append(options.NICs, nic)
+	}
+	options.nicFlags = nil
+	if len(options.NICs) == 0 {
+		return nil
+	}
+
+	gateways := 0
+	ips := map[string]bool{}
+	for i, nic := range options.NICs {
+		switch nic.Type {
+		case networkTypeNSXT:
+		case networkTypeVDS:
+			if nic.Name == "" {
+				return errors.Errorf("nic %d: name is required for network type %s", i, networkTypeVDS)
+			}
+		default:
+			return errors.Errorf("nic %d: invalid network type %q. valid values are %s and %s", i, nic.Type, networkTypeNSXT, networkTypeVDS)
+		}
+		if nic.Gateway != "" && nic.IP == "" {
+			return errors.Errorf("nic %d: gateway needs a static ip", i)
+		}
+		if nic.IP == "" {
+			continue
+		}
+		// NSX-T allocates the addresses of its segment ports and drops the traffic of other addresses
+		if nic.Type != networkTypeVDS {
+			return errors.Errorf("nic %d: static ips are only supported on %s networks", i, networkTypeVDS)
+		}
+		ip, subnet, err := net.ParseCIDR(nic.IP)
+		if err != nil {
+			return errors.Errorf("nic %d: invalid ip %q, expected an address in CIDR notation, e.g. 10.0.0.5/24", i, nic.IP)
+		}
+		if ips[ip.String()] {
+			return errors.Errorf("nic %d: duplicated ip %s", i, ip)
+		}
+		ips[ip.String()] = true
+		if nic.Gateway == "" {
+			continue
+		}
+		gateway := net.ParseIP(nic.Gateway)
+		if gateway == nil {
+			return errors.Errorf("nic %d: invalid gateway %q", i, nic.Gateway)
+		}
+		if !subnet.Contains(gateway) {
+			return errors.Errorf("nic %d: gateway %s is not in %s", i, gateway, subnet)
+		}
+		gateways++
+	}
+	if gateways > 1 {
+		return errors.New("only one nic can set a gateway")
+	}
+	options.NetworkType = options.NICs[0].Type
+	options.NetworkName = options.NICs[0].Name
+	return nil
+}
+
+// networkInterfaces are the network interfaces of the jumpbox, the --nic ones or the --network-type one
+func networkInterfaces() []NetworkInterface {
+	if len(options.NICs) > 0 {
+		return options.NICs
+	}
+	return []NetworkInterface{{Type: options.NetworkType, Name: options.NetworkName}}
+}
+
+// vmNetworkInterfaces are the network interfaces of the VirtualMachine
+func vmNetworkInterfaces() []v1alpha1.VirtualMachineNetworkInterface {
+	var interfaces []v1alpha1.VirtualMachineNetworkInterface
+	for _, nic := range networkInterfaces() {
+		interfaces = append(interfaces, v1alpha1.VirtualMachineNetworkInterface{
+			NetworkName: nic.Name,
+			NetworkType: nic.Type,
+		})
+	}
+	return interfaces
+}
+
+// nicsConfigured tells the interfaces need a network config. cloud-init only configures the first interface, with
+// DHCP
+func nicsConfigured() bool {
+	for i, nic := range options.NICs {
+		if i > 0 || nic.IP != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// nicsNetworkConfig is the cloud-init network config, version 2, of the interfaces. The interfaces are named @NIC<n>@
+// until the guest names are known on boot. Static interfaces use the --dns servers and search domains
+func nicsNetworkConfig() ([]byte, error) {
+	ethernets := map[string]interface{}{}
+	for i, nic := range options.NICs {
+		ethernet := map[string]interface{}{}
+		if nic.IP == "" {
+			ethernet["dhcp4"] = true
+		} else {
+			ethernet["dhcp4"] = false
+			ethernet["addresses"] = []interface{}{nic.IP}
+			if nic.Gateway != "" {
+				to := "0.0.0.0/0"
+				if strings.Contains(nic.Gateway, ":") {
+					to = "::/0"
+				}
+				ethernet["routes"] = []interface{}{map[string]interface{}{"to": to, "via": nic.Gateway}}
+			}
+			nameservers := map[string]interface{}{}
+			if len(options.Network.DNSServers) > 0 {
+				nameservers["addresses"] = options.Network.DNSServers
+			}
+			if len(options.Network.SearchDomains) > 0 {
+				nameservers["search"] = options.Network.SearchDomains
+			}
+			if len(nameservers) > 0 {
+				ethernet["nameservers"] = nameservers
+			}
+		}
+		ethernets[fmt.Sprintf("@NIC%d@", i)] = ethernet
+	}
+	data, err := yaml.Marshal(map[string]interface{}{
+		"network": map[string]interface{}{
+			"version":   2,
+			"ethernets": ethernets,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling network config")
+	}
+	return data, nil
+}
+
+// nicsCloudConfig is the cloud-config, merged over the template, that writes the network config of the interfaces on
+// boot, before packages are installed
+func nicsCloudConfig() ([]byte, error) {
+	config, err := nicsNetworkConfig()
+	if err != nil {
+		return nil, err
+	}
+	script := fmt.Sprintf(nicConfigScript, shellQuote(netplanConfigPath), shellQuote(string(config)),
+		shellQuote(cloudInitNetplanPath), shellQuote(cloudInitNetworkDisabledPath))
+	data, err := yaml.Marshal(map[string]interface{}{
+		"bootcmd": []interface{}{[]interface{}{"sh", "-c", script}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling nics cloud-config")
+	}
+	return append([]byte(cloudConfigHeader+"\n"), data...), nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1/install"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_resolveNICs(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []string
+		want    []NetworkInterface
+		wantErr bool
+	}{
+		{name: "none"},
+		{
+			name:  "dhcp-and-static",
+			flags: []string{"type=nsx-t", "type=vsphere-distributed,name=storage,ip=10.0.1.5/24,gateway=10.0.1.1"},
+			want: []NetworkInterface{
+				{Type: networkTypeNSXT},
+				{Type: networkTypeVDS, Name: "storage", IP: "10.0.1.5/24", Gateway: "10.0.1.1"},
+			},
+		},
+		{name: "unknown-field", flags: []string{"type=nsx-t,mtu=9000"}, wantErr: true},
+		{name: "invalid-type", flags: []string{"type=vlan"}, wantErr: true},
+		{name: "vds-without-name", flags: []string{"type=vsphere-distributed"}, wantErr: true},
+		{name: "static-on-nsx-t", flags: []string{"type=nsx-t,ip=10.0.1.5/24"}, wantErr: true},
+		{name: "ip-without-prefix", flags: []string{"type=vsphere-distributed,name=a,ip=10.0.1.5"}, wantErr: true},
+		{name: "gateway-without-ip", flags: []string{"type=vsphere-distributed,name=a,gateway=10.0.1.1"}, wantErr: true},
+		{name: "gateway-out-of-subnet", flags: []string{"type=vsphere-distributed,name=a,ip=10.0.1.5/24,gateway=10.0.2.1"}, wantErr: true},
+		{name: "duplicated-ip", flags: []string{"type=vsphere-distributed,name=a,ip=10.0.1.5/24", "type=vsphere-distributed,name=b,ip=10.0.1.5/16"}, wantErr: true},
+		{
+			name:    "two-gateways",
+			flags:   []string{"type=vsphere-distributed,name=a,ip=10.0.1.5/24,gateway=10.0.1.1", "type=vsphere-distributed,name=b,ip=10.0.2.5/24,gateway=10.0.2.1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{NetworkType: networkTypeVDS, NetworkName: "workload", nicFlags: tt.flags})
+			err := resolveNICs()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveNICs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(options.NICs, tt.want) {
+				t.Errorf("resolveNICs() NICs = %+v, want %+v", options.NICs, tt.want)
+			}
+			if len(tt.want) > 0 && (options.NetworkType != tt.want[0].Type || options.NetworkName != tt.want[0].Name) {
+				t.Errorf("resolveNICs() network = %s %s, want the first nic", options.NetworkType, options.NetworkName)
+			}
+		})
+	}
+}
+
+func Test_nicsCloudConfig(t *testing.T) {
+	setOptions(t, &VMOptions{
+		NICs: []NetworkInterface{
+			{Type: networkTypeNSXT},
+			{Type: networkTypeVDS, Name: "storage", IP: "10.0.1.5/24", Gateway: "10.0.1.1"},
+		},
+		Network: NetworkSettings{DNSServers: []string{"10.0.0.2"}, SearchDomains: []string{"corp.local"}},
+	})
+	if !nicsConfigured() {
+		t.Fatal("nicsConfigured() = false, want true")
+	}
+	doc, err := nicsCloudConfig()
+	if err != nil {
+		t.Fatalf("nicsCloudConfig() error = %v", err)
+	}
+	if err := validateUserData(doc); err != nil {
+		t.Errorf("nicsCloudConfig() is not valid user data: %v", err)
+	}
+	for _, want := range []string{"bootcmd:", netplanConfigPath, cloudInitNetplanPath, cloudInitNetworkDisabledPath, "@NIC0@", "@NIC1@", "10.0.1.5/24", "via: 10.0.1.1", "to: 0.0.0.0/0", "10.0.0.2", "corp.local", "netplan apply"} {
+		if !strings.Contains(string(doc), want) {
+			t.Errorf("nicsCloudConfig() doesn't contain %q:\n%s", want, doc)
+		}
+	}
+
+	options.NICs = []NetworkInterface{{Type: networkTypeNSXT}}
+	if nicsConfigured() {
+		t.Error("nicsConfigured() of a single DHCP nic = true, want false")
+	}
+}
+
+func Test_networkProblems_nics(t *testing.T) {
+	network := &unstructured.Unstructured{}
+	network.SetAPIVersion("netoperator.vmware.com/v1alpha1")
+	network.SetKind("Network")
+	network.SetName("workload")
+	network.SetNamespace("dev")
+	setDynamicClient(t, fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gvrNSXNetwork: "VirtualNetworkList",
+		gvrVDSNetwork: "NetworkList",
+	}, network))
+	setOptions(t, &VMOptions{
+		Namespace: "dev",
+		NICs: []NetworkInterface{
+			{Type: networkTypeVDS, Name: "workload"},
+			{Type: networkTypeVDS, Name: "storage", IP: "10.0.1.5/24"},
+		},
+	})
+
+	got := networkProblems(context.Background())
+	if len(got) != 1 || !strings.Contains(got[0], `"storage" not found`) || !strings.Contains(got[0], "workload") {
+		t.Errorf("networkProblems() got %v, want the storage network not found", got)
+	}
+}
+
+func Test_networkProblems_netplan(t *testing.T) {
+	image := func(name string, osType string) runtime.Object {
+		return &v1alpha1.VirtualMachineImage{
+			TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineImage", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.VirtualMachineImageSpec{OSInfo: v1alpha1.VirtualMachineImageOSInfo{Type: osType}},
+		}
+	}
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	setDynamicClient(t, fake.NewSimpleDynamicClient(scheme, image("ubuntu-20-1633387172196", "ubuntu64Guest"), image("centos-stream-8", "centos8_64Guest")))
+
+	tests := []struct {
+		name  string
+		image string
+		nics  []NetworkInterface
+		want  bool
+	}{
+		{name: "ubuntu", image: "ubuntu-20-1633387172196", nics: []NetworkInterface{{Type: networkTypeNSXT}, {Type: networkTypeNSXT}}},
+		{name: "centos-single-dhcp", image: "centos-stream-8", nics: []NetworkInterface{{Type: networkTypeNSXT}}},
+		{name: "centos-nics", image: "centos-stream-8", nics: []NetworkInterface{{Type: networkTypeNSXT}, {Type: networkTypeNSXT}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{Namespace: "dev", ImageName: tt.image, NICs: tt.nics})
+			got := networkProblems(context.Background())
+			if (len(got) > 0) != tt.want || (tt.want && !strings.Contains(got[0], "has no netplan")) {
+				t.Errorf("networkProblems() = %v, want netplan problem %v", got, tt.want)
+			}
+		})
+	}
+}
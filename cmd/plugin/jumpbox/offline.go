@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// offlineToolsDir is where the offline tool files are uploaded in the jumpbox. The install script reads the
+// downloads from it instead of the internet
+const offlineToolsDir = "/var/tmp/jumpbox-tools"
+
+// sshReadyTimeout is how long to wait for ssh and cloud-init after the VM gets an ip
+const sshReadyTimeout = 10 * time.Minute
+
+// offlineFile is a local file uploaded to the jumpbox for an offline install
+type offlineFile struct {
+	Name string
+	Path string
+}
+
+// offlineToolFiles finds the download of each tool in dir, named as the last element of its url, and verifies it
+// against the sha256 pinned in the manifest. A checksum file in dir would come from the same place as the download, so
+// tools without a pinned sha256 can't be installed offline
+func offlineToolFiles(dir string, tools []Tool) ([]offlineFile, error) {
+	var files []offlineFile
+	for _, tool := range tools {
+		if tool.SHA256 == "" {
+			return nil, errors.Errorf("tool %s has no pinned sha256 to verify its offline download. pin it in %s", tool.Name, userToolManifestPath())
+		}
+		name := path.Base(tool.URL)
+		file := filepath.Join(dir, name)
+		sum, err := fileSHA256(file)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error reading %s download", tool.Name))
+		}
+		if !strings.EqualFold(sum, tool.SHA256) {
+			return nil, errors.Errorf("checksum mismatch for %s: %s is %s, want %s", tool.Name, file, sum, tool.SHA256)
+		}
+		files = append(files, offlineFile{Name: name, Path: file})
+	}
+	return files, nil
+}
+
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// validateOfflineTools verifies the offline tool files before the jumpbox is created
+func validateOfflineTools() error {
+	if options.offlineToolsPath == "" {
+		return nil
+	}
+	tools, err := selectTools(options.Tools)
+	if err != nil {
+		return err
+	}
+	_, err = offlineToolFiles(localPath(options.offlineToolsPath), tools)
+	return err
+}
+
+// installOfflineTools waits for the jumpbox ssh, uploads the offline tool files and runs the tools install script
+func installOfflineTools(ctx context.Context) error {
+	if options.offlineToolsPath == "" || len(options.Tools) == 0 {
+		return nil
+	}
+	tools, err := selectTools(options.Tools)
+	if err != nil {
+		return err
+	}
+	files, err := offlineToolFiles(localPath(options.offlineToolsPath), tools)
+	if err != nil {
+		return err
+	}
+
+	client, err := waitSSH(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(client *ssh.Client) {
+		_ = client.Close()
+	}(client)
+
+	_, err = runRemote(client, fmt.Sprintf("sudo install -d -m 0755 -o \"$(id -u)\" %s", offlineToolsDir), nil)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Printf("uploading %s\n", file.Name)
+		err = uploadFile(client, file.Path, offlineToolsDir+"/"+file.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := runRemote(client, fmt.Sprintf("sudo JUMPBOX_TOOLS_DIR=%s %s", offlineToolsDir, toolsScriptPath), nil)
+	fmt.Print(out)
+	if err != nil {
+		return errors.WithMessage(err, "error installing tools")
+	}
+	_, err = runRemote(client, fmt.Sprintf("sudo rm -rf %s", offlineToolsDir), nil)
+	return err
+}
+
+// uploadFile streams a local file to the jumpbox over an ssh session
+func uploadFile(client *ssh.Client, src string, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "error opening file")
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	_, err = runRemote(client, fmt.Sprintf("cat > %s", shellQuote(dst)), f)
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("error uploading %s", src))
+	}
+	return nil
+}
+
+// waitSSH waits until the jumpbox accepts ssh connections and cloud-init is done
+func waitSSH(ctx context.Context) (*ssh.Client, error) {
+	fmt.Print("\nwaiting for ssh ")
+	deadline := time.Now().Add(sshReadyTimeout)
+	for {
+		fmt.Print(".")
+		client, err := dialJumpboxAdmin(ctx)
+		if err == nil {
+			_, err = runRemote(client, "cloud-init status --wait > /dev/null || true", nil)
+			if err == nil {
+				fmt.Println()
+				return client, nil
+			}
+			_ = client.Close()
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.WithMessage(err, "timed out waiting for ssh")
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// aptMirrorCloudConfig is the cloud-config that installs packages from a local apt mirror
+func aptMirrorCloudConfig(uri string) []byte {
+	return []byte(fmt.Sprintf(`%s
+apt:
+  primary:
+    - arches: [default]
+      uri: %[2]q
+  security:
+    - arches: [default]
+      uri: %[2]q
+`, cloudConfigHeader, uri))
+}
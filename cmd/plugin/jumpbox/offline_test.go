@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_offlineToolFiles(t *testing.T) {
+	sumOf := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+	kubectlSHA256 := sumOf("kubectl")
+
+	tests := []struct {
+		name      string
+		tools     []Tool
+		files     map[string]string
+		wantFiles []string
+		wantErr   bool
+	}{
+		{
+			name: "not-pinned",
+			tools: []Tool{{Name: "kapp", Version: "v0.58.0", URL: "https://github.com/carvel-dev/kapp/releases/download/v0.58.0/kapp-linux-amd64",
+				ChecksumURL: "https://github.com/carvel-dev/kapp/releases/download/v0.58.0/checksums.txt"}},
+			files:   map[string]string{"kapp-linux-amd64": "kapp", "checksums.txt": sumOf("kapp") + "  ./kapp-linux-amd64\n"},
+			wantErr: true,
+		},
+		{
+			name:      "pinned",
+			tools:     []Tool{{Name: "kubectl", URL: "https://dl.k8s.io/kubectl", SHA256: kubectlSHA256}},
+			files:     map[string]string{"kubectl": "kubectl"},
+			wantFiles: []string{"kubectl"},
+		},
+		{
+			name:    "mismatch",
+			tools:   []Tool{{Name: "kubectl", URL: "https://dl.k8s.io/kubectl", SHA256: "abc"}},
+			files:   map[string]string{"kubectl": "kubectl"},
+			wantErr: true,
+		},
+		{
+			name:    "missing-download",
+			tools:   []Tool{{Name: "kubectl", URL: "https://dl.k8s.io/kubectl", SHA256: kubectlSHA256}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := offlineToolFiles(dir, tt.tools)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("offlineToolFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, file := range got {
+				names = append(names, file.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantFiles) {
+				t.Errorf("offlineToolFiles() = %v, want %v", names, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func Test_aptMirrorCloudConfig(t *testing.T) {
+	doc, err := mergeCloudConfig([]byte("#cloud-config\npackages:\n  - git\n"), aptMirrorCloudConfig("http://mirror.local/ubuntu"))
+	if err != nil {
+		t.Fatalf("mergeCloudConfig() error = %v", err)
+	}
+	if err := validateUserData(doc); err != nil {
+		t.Errorf("aptMirrorCloudConfig() is invalid: %v\n%s", err, doc)
+	}
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
-	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"github.com/pkg/errors"
-	"text/template"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
 )
 
 type (
@@ -20,185 +25,76 @@ type (
 		SSHPublicKey     string
 		SSHPrivateKey    string
 		User             string
+		Groups           string
+		Shell            string
+		Sudo             string
+		AccessUsers      []AccessUser
+		Values           map[string]interface{}
+		Template         string
+		Tools            []string
+		AptMirror        string
+		Transport        string
+		Bootstrap        string
+		Secrets          map[string]interface{}
+		Network          NetworkSettings
+		Disk             DiskSettings
+		Volumes          []VolumeSettings
+		OS               string
+		CPUs             int
+		Memory           string
+		Ports            []ServicePort
+		NICs             []NetworkInterface
 
-		pvcName           string
-		configName        string
-		svcName           string
-		sshSecretName     string
-		sshPrivateKeyPath string
-		tanzuDir          string
+		pvcName             string
+		configName          string
+		svcName             string
+		sshSecretName       string
+		sshPrivateKeyPath   string
+		tanzuDir            string
+		accessConfigName    string
+		accessUser          string
+		accessKeyPath       string
+		userDataPath        string
+		userDataText        string
+		caCerts             []string
+		baseDir             string
+		dotfilesSHA256      string
+		offlineToolsSHA256  string
+		valuesPath          string
+		setValues           []string
+		mergeUserData       bool
+		offlineToolsPath    string
+		bootstrapSecretName string
+		secretValuesPath    string
+		setSecrets          []string
+		dotfilesPath        string
+		volumeFlags         []string
+		keepVolumes         bool
+		expandVolume        string
+		output              string
+		dryRun              string
+		dryRunOutput        string
+		outputDir           string
+		preflightOnly       bool
+		profile             string
+		portFlags           []string
+		nicFlags            []string
 	}
 )
 
-var userdata = `
-#cloud-config
-repo_update: true
-repo_upgrade: all
-
-ssh_authorized_keys:
-  - {{ .SSHPublicKey }} 
-
-users:
-  - default
-  - name: operator
-    groups: sudo
-    shell: /bin/bash
-    sudo: ['ALL=(ALL) NOPASSWD:ALL']
-    ssh-authorized-keys:
-			- {{ .SSHPublicKey }} 
-
-packages:
-	- httpd
-
-fs_setup:
-  - label: workspace
-    filesystem: 'ext4'
-    device: '/dev/sdb'
-    partition: 'auto'
-
-write_files:
-	-	path: /home/operator/jump-start.sh
-		permissions: '0744'
-		content: |
-		  wget https://github.com/vmware-tanzu/tanzu-cli/releases/download/v1.0.0/tanzu-cli-linux-amd64.tar.gz	
-			tar -xvf tanzu-cli-linux-amd64.tar.gz
-			pushd v1.0.0
-			sudo install tanzu-cli-linux_amd64 /usr/local/bin/tanzu
-			popd
-
-	-	path: /home/operator/.bash_rc
-		permissions: '0644'
-	  content: |
-			# ~/.bashrc: executed by bash(1) for non-login shells.
-			# see /usr/share/doc/bash/examples/startup-files (in the package bash-doc)
-			# for examples
-			
-			# If not running interactively, don't do anything
-			case $- in
-					*i*) ;;
-						*) return;;
-			esac
-			
-			# don't put duplicate lines or lines starting with space in the history.
-			# See bash(1) for more options
-			HISTCONTROL=ignoreboth
-			
-			# append to the history file, don't overwrite it
-			shopt -s histappend
-			
-			# for setting history length see HISTSIZE and HISTFILESIZE in bash(1)
-			HISTSIZE=1000
-			HISTFILESIZE=2000
-			
-			# check the window size after each command and, if necessary,
-			# update the values of LINES and COLUMNS.
-			shopt -s checkwinsize
-			
-			# If set, the pattern "**" used in a pathname expansion context will
-			# match all files and zero or more directories and subdirectories.
-			#shopt -s globstar
-			
-			# make less more friendly for non-text input files, see lesspipe(1)
-			[ -x /usr/bin/lesspipe ] && eval "$(SHELL=/bin/sh lesspipe)"
-			
-			# set variable identifying the chroot you work in (used in the prompt below)
-			if [ -z "${debian_chroot:-}" ] && [ -r /etc/debian_chroot ]; then
-					debian_chroot=$(cat /etc/debian_chroot)
-			fi
-			
-			# set a fancy prompt (non-color, unless we know we "want" color)
-			case "$TERM" in
-					xterm-color|*-256color) color_prompt=yes;;
-			esac
-			
-			# uncomment for a colored prompt, if the terminal has the capability; turned
-			# off by default to not distract the user: the focus in a terminal window
-			# should be on the output of commands, not on the prompt
-			#force_color_prompt=yes
-			
-			if [ -n "$force_color_prompt" ]; then
-					if [ -x /usr/bin/tput ] && tput setaf 1 >&/dev/null; then
-							# We have color support; assume it's compliant with Ecma-48
-							# (ISO/IEC-6429). (Lack of such support is extremely rare, and such
-							# a case would tend to support setf rather than setaf.)
-							color_prompt=yes
-					else
-							color_prompt=
-					fi
-			fi
-			
-			if [ "$color_prompt" = yes ]; then
-					PS1='${debian_chroot:+($debian_chroot)}\[\033[01;32m\]\u@\h\[\033[00m\]:\[\033[01;34m\]\w\[\033[00m\]\$ '
-			else
-					PS1='${debian_chroot:+($debian_chroot)}\u@\h:\w\$ '
-			fi
-			unset color_prompt force_color_prompt
-			
-			# If this is an xterm set the title to user@host:dir
-			case "$TERM" in
-			xterm*|rxvt*)
-					PS1="\[\e]0;${debian_chroot:+($debian_chroot)}\u@\h: \w\a\]$PS1"
-					;;
-			*)
-					;;
-			esac
-			
-			# enable color support of ls and also add handy aliases
-			if [ -x /usr/bin/dircolors ]; then
-					test -r ~/.dircolors && eval "$(dircolors -b ~/.dircolors)" || eval "$(dircolors -b)"
-					alias ls='ls --color=auto'
-					#alias dir='dir --color=auto'
-					#alias vdir='vdir --color=auto'
-			
-					alias grep='grep --color=auto'
-					alias fgrep='fgrep --color=auto'
-					alias egrep='egrep --color=auto'
-			fi
-			
-			# colored GCC warnings and errors
-			#export GCC_COLORS='error=01;31:warning=01;35:note=01;36:caret=01;32:locus=01:quote=01'
-			
-			# some more ls aliases
-			alias ll='ls -alF'
-			alias la='ls -A'
-			alias l='ls -CF'
-			
-			# Add an "alert" alias for long running commands.  Use like so:
-			#   sleep 10; alert
-			alias alert='notify-send --urgency=low -i "$([ $? = 0 ] && echo terminal || echo error)" "$(history|tail -n1|sed -e '\''s/^\s*[0-9]\+\s*//;s/[;&|]\s*alert$//'\'')"'
-			
-			# Alias definitions.
-			# You may want to put all your additions into a separate file like
-			# ~/.bash_aliases, instead of adding them here directly.
-			# See /usr/share/doc/bash-doc/examples in the bash-doc package.
-			
-			if [ -f ~/.bash_aliases ]; then
-					. ~/.bash_aliases
-			fi
-			
-			# enable programmable completion features (you don't need to enable
-			# this, if it's already enabled in /etc/bash.bashrc and /etc/profile
-			# sources /etc/bash.bashrc).
-			if ! shopt -oq posix; then
-				if [ -f /usr/share/bash-completion/bash_completion ]; then
-					. /usr/share/bash-completion/bash_completion
-				elif [ -f /etc/bash_completion ]; then
-					. /etc/bash_completion
-				fi
-			fi
-			source <(kubectl completion bash)
-			alias k=kubectl
-			complete -o default -F __start_kubectl k
-				# My new /etc/sysconfig/samba file
-		
-		
-
-mounts:
-  - [ sdb, /home/operator ]
+// defaults of the jumpbox user, shared by the create flags, the manifests and the jumpboxes created before the spec
+const (
+	defaultUser   = "operator"
+	defaultGroups = "sudo"
+	defaultShell  = "/bin/bash"
+)
 
-runcmd:
-  - bash /home/operator/jump-start.sh
-`
+// sudo policies supported for the jumpbox user
+const (
+	sudoNone     = "none"
+	sudoPassword = "password"
+	sudoNoPasswd = "nopasswd"
+)
 
 func setup(args []string) {
 	vmName := args[0]
@@ -207,24 +103,211 @@ func setup(args []string) {
 	options.sshSecretName = vmName + "-ssh"
 	options.configName = vmName + "-cm"
 	options.svcName = vmName + "-svc"
+	options.accessConfigName = vmName + "-access"
+	options.bootstrapSecretName = vmName + "-bootstrap"
+}
+
+// shellRegex matches an absolute path, without the characters that would need quoting in the cloud-config
+var shellRegex = regexp.MustCompile(`^(/[A-Za-z0-9._+-]+)+$`)
+
+// validateUser checks the user to be provisioned in the jumpbox
+func validateUser() error {
+	if !linuxUserRegex.MatchString(options.User) {
+		return errors.Errorf("invalid user name %q", options.User)
+	}
+	if options.Groups != "" {
+		for _, group := range strings.Split(options.Groups, ",") {
+			if !linuxUserRegex.MatchString(group) {
+				return errors.Errorf("invalid group name %q in groups %q", group, options.Groups)
+			}
+		}
+	}
+	if !shellRegex.MatchString(options.Shell) {
+		return errors.Errorf("invalid shell %q, expected an absolute path", options.Shell)
+	}
+	switch options.Sudo {
+	case sudoNone, sudoPassword, sudoNoPasswd:
+	default:
+		return errors.Errorf("invalid sudo policy %q. valid values are %s, %s and %s", options.Sudo, sudoNone, sudoPassword, sudoNoPasswd)
+	}
+	return nil
 }
 
 func buildUserdata() error {
-	sshPrivateKey, sshPubKey, err := MakeSSHKeyPair()
+	if options.SSHPublicKey == "" {
+		sshPrivateKey, sshPubKey, err := MakeSSHKeyPair()
+		if err != nil {
+			return errors.WithMessage(err, "err creating ssh key pair")
+		}
+		options.SSHPublicKey = string(sshPubKey)
+		options.SSHPrivateKey = string(sshPrivateKey)
+	}
+
+	doc, err := renderUserdataDoc()
+	if err != nil {
+		return err
+	}
+	err = validateUserData(doc)
 	if err != nil {
-		return errors.WithMessage(err, "err creating ssh key pair")
+		return err
 	}
-	options.SSHPublicKey = string(sshPubKey)
-	options.SSHPrivateKey = string(sshPrivateKey)
+	options.UserData = base64.StdEncoding.EncodeToString(doc)
 
-	t := template.Must(template.New("userdata").Parse(userdata))
+	return nil
+}
 
-	buf := new(bytes.Buffer)
-	err = t.Execute(buf, options)
+// renderUserdataDoc renders the --template user data, or the --user-data template when set
+func renderUserdataDoc() ([]byte, error) {
+	values, err := loadValues()
+	if err != nil {
+		return nil, err
+	}
+	options.Values = values
+	secrets, err := loadSecrets()
 	if err != nil {
-		return errors.WithMessage(err, "err building userdata template")
+		return nil, err
 	}
-	options.UserData = base64.StdEncoding.EncodeToString(buf.Bytes())
+	options.Secrets = secrets
 
-	return nil
+	if options.Template == "" {
+		options.Template = defaultTemplate
+	}
+	t, err := getTemplate(options.Template)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := renderTemplate(t.Name, t.Text)
+	if err != nil {
+		return nil, err
+	}
+	err = resolveDisk()
+	if err != nil {
+		return nil, err
+	}
+	err = resolveVolumes()
+	if err != nil {
+		return nil, err
+	}
+	overlay, err := disksCloudConfig()
+	if err != nil {
+		return nil, err
+	}
+	doc, err = mergeCloudConfig(doc, overlay)
+	if err != nil {
+		return nil, err
+	}
+	if options.Tools == nil {
+		options.Tools = templateTools(t.Text)
+	}
+	if options.offlineToolsPath != "" {
+		options.offlineToolsSHA256, err = dirSHA256(localPath(options.offlineToolsPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading offline tools directory. set --offline-tools")
+		}
+	}
+	if len(options.Tools) > 0 {
+		overlay, err := toolsCloudConfig(options.Tools, options.offlineToolsPath != "")
+		if err != nil {
+			return nil, err
+		}
+		doc, err = mergeCloudConfig(doc, overlay)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = resolveNetworkSettings()
+	if err != nil {
+		return nil, err
+	}
+	if !options.Network.empty() {
+		overlay, err := networkCloudConfig(&options.Network, options.caCerts)
+		if err != nil {
+			return nil, err
+		}
+		doc, err = mergeCloudConfig(doc, overlay)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if nicsConfigured() {
+		overlay, err := nicsCloudConfig()
+		if err != nil {
+			return nil, err
+		}
+		doc, err = mergeCloudConfig(doc, overlay)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dir, ok := dotfilesDir(); ok {
+		options.dotfilesSHA256, err = dirSHA256(dir)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading dotfiles")
+		}
+		dotfiles, err := collectDotfiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		overlay, err := dotfilesCloudConfig(dotfiles, options.User)
+		if err != nil {
+			return nil, err
+		}
+		doc, err = mergeCloudConfig(doc, overlay)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if options.AptMirror != "" {
+		doc, err = mergeCloudConfig(doc, aptMirrorCloudConfig(options.AptMirror))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if options.userDataPath != "" {
+		doc, err = renderUserData(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// localPath returns the workstation path of a path given in a manifest, relative to the manifest, or as a flag
+func localPath(path string) string {
+	if path == "" || filepath.IsAbs(path) || options.baseDir == "" {
+		return path
+	}
+	return filepath.Join(options.baseDir, path)
+}
+
+// dirSHA256 hashes the names, modes and contents of the files in dir, so the spec records the content of the
+// directories it references without their workstation paths
+func dirSHA256(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sum, err := fileSHA256(p)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(h, "%s %o %s\n", filepath.ToSlash(rel), info.Mode().Perm(), sum)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -42,3 +42,83 @@ func Test_setup(t *testing.T) {
 		})
 	}
 }
+
+func Test_validateUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		groups  string
+		shell   string
+		sudo    string
+		wantErr bool
+	}{
+		{
+			name:   "operator-nopasswd",
+			user:   "operator",
+			groups: "sudo",
+			shell:  "/bin/bash",
+			sudo:   sudoNoPasswd,
+		},
+		{
+			name:   "alice-password",
+			user:   "alice",
+			groups: "sudo,docker",
+			shell:  "/usr/bin/zsh",
+			sudo:   sudoPassword,
+		},
+		{
+			name:    "groups-injection",
+			user:    "alice",
+			groups:  "sudo\nruncmd: [reboot]",
+			shell:   "/bin/bash",
+			sudo:    sudoNone,
+			wantErr: true,
+		},
+		{
+			name:    "groups-comment",
+			user:    "alice",
+			groups:  "sudo #admins",
+			shell:   "/bin/bash",
+			sudo:    sudoNone,
+			wantErr: true,
+		},
+		{
+			name:    "relative-shell",
+			user:    "alice",
+			groups:  "sudo",
+			shell:   "bash",
+			sudo:    sudoNone,
+			wantErr: true,
+		},
+		{
+			name:    "shell-key",
+			user:    "alice",
+			groups:  "sudo",
+			shell:   "/bin/bash: x",
+			sudo:    sudoNone,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-user",
+			user:    "Alice Smith",
+			shell:   "/bin/bash",
+			sudo:    sudoNone,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-sudo",
+			user:    "alice",
+			shell:   "/bin/bash",
+			sudo:    "always",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{User: tt.user, Groups: tt.groups, Shell: tt.shell, Sudo: tt.sudo})
+			if err := validateUser(); (err != nil) != tt.wantErr {
+				t.Errorf("validateUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
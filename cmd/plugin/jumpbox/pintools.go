@@ -0,0 +1,135 @@
+//go:build ignore
+
+// pintools pins the sha256 of every tool in manifests/tools.yaml from its checksumUrl.
+// Run it with `go generate` after changing a tool version
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sigs.k8s.io/yaml/goyaml.v3"
+	"strings"
+)
+
+const manifestPath = "manifests/tools.yaml"
+
+func main() {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	doc := &yaml.Node{}
+	err = yaml.Unmarshal(data, doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tools := mappingValue(doc.Content[0], "tools")
+	if tools == nil {
+		log.Fatalf("%s has no tools", manifestPath)
+	}
+	for _, tool := range tools.Content {
+		name := scalar(tool, "name")
+		url := scalar(tool, "url")
+		checksumURL := scalar(tool, "checksumUrl")
+		if checksumURL == "" {
+			log.Fatalf("tool %s has no checksumUrl to pin its sha256 from", name)
+		}
+		checksums, err := download(checksumURL)
+		if err != nil {
+			log.Fatalf("error downloading the checksums of tool %s: %v", name, err)
+		}
+		sum := lookupChecksum(checksums, path.Base(url))
+		if sum == "" {
+			log.Fatalf("checksum of %s not found in %s", path.Base(url), checksumURL)
+		}
+		setScalar(tool, "sha256", sum, "url")
+		fmt.Printf("%s %s %s\n", name, scalar(tool, "version"), sum)
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	err = encoder.Encode(doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = os.WriteFile(manifestPath, buf.Bytes(), 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func download(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// lookupChecksum mirrors the lookup of offline.go: a file with a single checksum or `sha256  name` lines
+func lookupChecksum(checksums string, name string) string {
+	fields := strings.Fields(checksums)
+	if len(fields) == 1 {
+		return fields[0]
+	}
+	for _, line := range strings.Split(checksums, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		file := strings.TrimPrefix(fields[1], "*")
+		if file == name || strings.HasSuffix(file, "/"+name) {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func scalar(node *yaml.Node, key string) string {
+	if value := mappingValue(node, key); value != nil {
+		return value.Value
+	}
+	return ""
+}
+
+// setScalar sets key to value, inserting it after the after key when missing
+func setScalar(node *yaml.Node, key string, value string, after string) {
+	if existing := mappingValue(node, key); existing != nil {
+		existing.Value = value
+		return
+	}
+	pair := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: key},
+		{Kind: yaml.ScalarNode, Value: value},
+	}
+	at := len(node.Content)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == after {
+			at = i + 2
+		}
+	}
+	node.Content = append(node.Content[:at], append(pair, node.Content[at:]...)...)
+}
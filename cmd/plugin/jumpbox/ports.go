@@ -0,0 +1,121 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"strconv"
+	"strings"
+)
+
+// sshPortName is the service port every jumpbox has
+const sshPortName = "ssh"
+
+// ServicePort is an extra port of the jumpbox load balancer
+type ServicePort struct {
+	Name string `json:"name"`
+	Port int32  `json:"port"`
+	// TargetPort defaults to Port
+	TargetPort int32 `json:"targetPort,omitempty"`
+	// Protocol is TCP or UDP, TCP by default
+	Protocol string `json:"protocol,omitempty"`
+}
+
+func addPortFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&options.portFlags, "port", "", nil, "Extra load balancer port, `name=<n>,port=<p>[,target-port=<t>][,protocol=TCP|UDP]`. Can be repeated")
+}
+
+func parsePort(flag string) (ServicePort, error) {
+	port := ServicePort{}
+	for _, field := range strings.Split(flag, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return port, errors.Errorf("invalid port %q, expected key=value fields", flag)
+		}
+		switch kv[0] {
+		case "name":
+			port.Name = kv[1]
+		case "port", "target-port":
+			n, err := strconv.ParseInt(kv[1], 10, 32)
+			if err != nil {
+				return port, errors.Errorf("invalid port %q, %s is not a number", flag, kv[0])
+			}
+			if kv[0] == "port" {
+				port.Port = int32(n)
+			} else {
+				port.TargetPort = int32(n)
+			}
+		case "protocol":
+			port.Protocol = kv[1]
+		default:
+			return port, errors.Errorf("invalid port %q, unknown field %q", flag, kv[0])
+		}
+	}
+	return port, nil
+}
+
+// resolvePorts adds the --port flags to the service ports and validates them
+func resolvePorts() error {
+	for _, flag := range options.portFlags {
+		port, err := parsePort(flag)
+		if err != nil {
+			return err
+		}
+		options.Ports = append(options.Ports, port)
+	}
+	options.portFlags = nil
+
+	names := map[string]bool{sshPortName: true}
+	ports := map[int32]bool{22: true}
+	for i, port := range options.Ports {
+		if msgs := validation.IsDNS1123Label(port.Name); len(msgs) > 0 {
+			return errors.Errorf("invalid port name %q: %s", port.Name, strings.Join(msgs, ", "))
+		}
+		if names[port.Name] {
+			return errors.Errorf("duplicated port name %q", port.Name)
+		}
+		names[port.Name] = true
+		if msgs := validation.IsValidPortNum(int(port.Port)); len(msgs) > 0 {
+			return errors.Errorf("invalid port %s %d: %s", port.Name, port.Port, strings.Join(msgs, ", "))
+		}
+		if ports[port.Port] {
+			return errors.Errorf("duplicated port %d", port.Port)
+		}
+		ports[port.Port] = true
+		if port.TargetPort == 0 {
+			options.Ports[i].TargetPort = port.Port
+		}
+		if msgs := validation.IsValidPortNum(int(options.Ports[i].TargetPort)); len(msgs) > 0 {
+			return errors.Errorf("invalid target port of %s %d: %s", port.Name, port.TargetPort, strings.Join(msgs, ", "))
+		}
+		switch strings.ToUpper(port.Protocol) {
+		case "", "TCP":
+			options.Ports[i].Protocol = "TCP"
+		case "UDP":
+			options.Ports[i].Protocol = "UDP"
+		default:
+			return errors.Errorf("invalid protocol %q of port %s. valid values are TCP and UDP", port.Protocol, port.Name)
+		}
+	}
+	return nil
+}
+
+// servicePorts are the ports of the jumpbox VirtualMachineService, ssh first
+func servicePorts() []v1alpha1.VirtualMachineServicePort {
+	ports := []v1alpha1.VirtualMachineServicePort{{
+		Name:       sshPortName,
+		Protocol:   "TCP",
+		Port:       22,
+		TargetPort: 22,
+	}}
+	for _, port := range options.Ports {
+		ports = append(ports, v1alpha1.VirtualMachineServicePort{
+			Name:       port.Name,
+			Protocol:   port.Protocol,
+			Port:       port.Port,
+			TargetPort: port.TargetPort,
+		})
+	}
+	return ports
+}
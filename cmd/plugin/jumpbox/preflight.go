@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sort"
+	"strings"
+)
+
+const (
+	networkTypeNSXT = "nsx-t"
+	networkTypeVDS  = "vsphere-distributed"
+)
+
+// createPermission is a verb on a resource that create needs
+type createPermission struct {
+	verb     string
+	group    string
+	resource string
+}
+
+// preflight checks everything create needs before it writes to the cluster, and reports all the problems found at once
+func preflight(ctx context.Context) error {
+	var problems []string
+	problems = append(problems, diskProblems(ctx)...)
+	problems = append(problems, nameProblems()...)
+	problems = append(problems, networkProblems(ctx)...)
+	problems = append(problems, imageProblems(ctx)...)
+	class, classIssues := classProblems(ctx)
+	problems = append(problems, classIssues...)
+	problems = append(problems, storageClassProblems(ctx)...)
+	// a client dry run creates nothing, its objects may be applied later by someone else
+	if options.dryRun != dryRunClient {
+		problems = append(problems, quotaProblems(ctx, class)...)
+		problems = append(problems, permissionProblems(ctx)...)
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("preflight found %d problems:\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// nameProblems checks the jumpbox name and the names of the resources derived from it. The VM name is its hostname
+// and the service name must be a DNS-1035 label
+func nameProblems() []string {
+	var problems []string
+	check := func(kind string, name string, validate func(string) []string) {
+		for _, msg := range validate(name) {
+			problems = append(problems, fmt.Sprintf("%s name %q is invalid: %s", kind, name, msg))
+		}
+	}
+	check("vm", options.Name, validation.IsDNS1123Label)
+	check("service", options.svcName, validation.IsDNS1035Label)
+	check("pvc", options.pvcName, validation.IsDNS1123Subdomain)
+	check("ssh secret", options.sshSecretName, validation.IsDNS1123Subdomain)
+	check("config map", options.configName, validation.IsDNS1123Subdomain)
+	check("bootstrap secret", options.bootstrapSecretName, validation.IsDNS1123Subdomain)
+	check("access config map", options.accessConfigName, validation.IsDNS1123Subdomain)
+	for _, volume := range options.Volumes {
+		check("volume pvc", volumePVCName(volume.Name), validation.IsDNS1123Subdomain)
+	}
+	return problems
+}
+
+// diskProblems validates the workspace disk and the extra volumes, so their PVCs are known to the quota checks, and
+// the sizes of the disks that are new
+func diskProblems(ctx context.Context) []string {
+	err := resolveDisk()
+	if err == nil {
+		err = resolveVolumes()
+	}
+	if err != nil {
+		return []string{err.Error()}
+	}
+	disks, err := newDisks(ctx)
+	if err == nil {
+		err = checkNewDiskSizes(disks)
+	}
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+func networkProblems(ctx context.Context) []string {
+	// the --nic interfaces are validated by resolveNICs
+	if len(options.NICs) == 0 {
+		switch options.NetworkType {
+		case networkTypeNSXT:
+		case networkTypeVDS:
+			if options.NetworkName == "" {
+				return []string{"--network-name is required for network type " + networkTypeVDS}
+			}
+		default:
+			return []string{fmt.Sprintf("invalid network type %q. valid values are %s and %s", options.NetworkType, networkTypeNSXT, networkTypeVDS)}
+		}
+	}
+	var problems []string
+	if nicsConfigured() {
+		// a missing image is reported by imageProblems
+		image, err := getVMImage(ctx, options.ImageName)
+		if err == nil && !imageHasNetplan(image) {
+			problems = append(problems, fmt.Sprintf("image %s has no netplan, which applies the network config of several nics or static ips. use an ubuntu %s or later image", options.ImageName, netplanMinUbuntu))
+		}
+	}
+
+	var named []NetworkInterface
+	for _, nic := range networkInterfaces() {
+		if nic.Name != "" {
+			named = append(named, nic)
+		}
+	}
+	if len(named) == 0 {
+		return problems
+	}
+
+	networks, err := listNetworks(ctx)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+	for _, nic := range named {
+		found := false
+		var names []string
+		for _, network := range networks {
+			if network.Name == nic.Name && network.Type == nic.Type {
+				found = true
+			}
+			if network.Type == nic.Type {
+				names = append(names, network.Name)
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("network %s %q not found in namespace %s. available: %s", nic.Type, nic.Name, options.Namespace, available(names)))
+		}
+	}
+	return problems
+}
+
+func imageProblems(ctx context.Context) []string {
+	image, err := getVMImage(ctx, options.ImageName)
+	if apierrors.IsNotFound(err) {
+		return []string{fmt.Sprintf("image %q not found", options.ImageName)}
+	}
+	if err != nil {
+		return []string{errors.Wrap(err, "error getting image").Error()}
+	}
+	if image.Status.ImageSupported != nil && !*image.Status.ImageSupported {
+		return []string{fmt.Sprintf("image %q is not supported by the VM Service", options.ImageName)}
+	}
+
+	providers, err := boundContentProviders(ctx)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if providers != nil && !providers[image.Spec.ProviderRef.Name] {
+		return []string{fmt.Sprintf("image %q is not in a content library bound to namespace %s", options.ImageName, options.Namespace)}
+	}
+	return nil
+}
+
+// classProblems checks the class is bound to the namespace and returns it for the quota checks
+func classProblems(ctx context.Context) (*ClassInfo, []string) {
+	classes, err := listClasses(ctx)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	var names []string
+	for i := range classes {
+		if classes[i].Name == options.ClassName {
+			return &classes[i], nil
+		}
+		names = append(names, classes[i].Name)
+	}
+	return nil, []string{fmt.Sprintf("vm class %q is not bound to namespace %s. available: %s", options.ClassName, options.Namespace, available(names))}
+}
+
+func storageClassProblems(ctx context.Context) []string {
+	storageClasses, err := listStorageClasses(ctx)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	allowed := map[string]bool{}
+	var names []string
+	for _, sc := range storageClasses {
+		allowed[sc.Name] = true
+		names = append(names, sc.Name)
+	}
+
+	var problems []string
+	for _, name := range requestedStorage().storageClasses {
+		if !allowed[name] {
+			problems = append(problems, fmt.Sprintf("storage class %q is not assigned to namespace %s. available: %s", name, options.Namespace, available(names)))
+		}
+	}
+	return problems
+}
+
+// storageRequest is the storage create requests, by storage class
+type storageRequest struct {
+	storageClasses []string
+	sizes          map[string]resource.Quantity
+	claims         map[string]int64
+}
+
+func requestedStorage() storageRequest {
+	request := storageRequest{sizes: map[string]resource.Quantity{}, claims: map[string]int64{}}
+	add := func(storageClass string, size string) {
+		q, err := parseDiskSize(size)
+		if err != nil {
+			return
+		}
+		if _, ok := request.sizes[storageClass]; !ok {
+			request.storageClasses = append(request.storageClasses, storageClass)
+		}
+		total := request.sizes[storageClass]
+		total.Add(q)
+		request.sizes[storageClass] = total
+		request.claims[storageClass]++
+	}
+	add(options.StorageClassName, options.Disk.Size)
+	for _, volume := range options.Volumes {
+		add(volume.StorageClass, volume.Size)
+	}
+	return request
+}
+
+// requestedResources is what create adds to the namespace ResourceQuota usage
+func requestedResources(class *ClassInfo) corev1.ResourceList {
+	resources := corev1.ResourceList{}
+	add := func(name corev1.ResourceName, q resource.Quantity) {
+		total := resources[name]
+		total.Add(q)
+		resources[name] = total
+	}
+
+	request := requestedStorage()
+	for _, storageClass := range request.storageClasses {
+		add(corev1.ResourceRequestsStorage, request.sizes[storageClass])
+		add(corev1.ResourceName(storageClass+storageClassQuotaSuffix), request.sizes[storageClass])
+		claims := *resource.NewQuantity(request.claims[storageClass], resource.DecimalSI)
+		add(corev1.ResourcePersistentVolumeClaims, claims)
+		add(corev1.ResourceName(storageClass+".storageclass.storage.k8s.io/persistentvolumeclaims"), claims)
+	}
+
+	if class != nil {
+		memory, err := resource.ParseQuantity(class.Memory)
+		if err == nil {
+			add(corev1.ResourceLimitsMemory, memory)
+		}
+		add(corev1.ResourceLimitsCPU, *resource.NewQuantity(class.CPUs, resource.DecimalSI))
+	}
+	return resources
+}
+
+// quotaProblems checks the namespace ResourceQuotas have headroom for the storage and compute of the jumpbox
+func quotaProblems(ctx context.Context, class *ClassInfo) []string {
+	quotas, err := c.CoreV1().ResourceQuotas(options.Namespace).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return []string{errors.Wrap(err, "error listing resource quotas").Error()}
+	}
+	return quotaHeadroom(quotas.Items, requestedResources(class))
+}
+
+func quotaHeadroom(quotas []corev1.ResourceQuota, requested corev1.ResourceList) []string {
+	var problems []string
+	for _, quota := range quotas {
+		keys := make([]string, 0, len(requested))
+		for name := range requested {
+			keys = append(keys, string(name))
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := corev1.ResourceName(key)
+			hard, ok := quota.Spec.Hard[name]
+			if !ok {
+				continue
+			}
+			need := requested[name]
+			free := hard.DeepCopy()
+			free.Sub(quota.Status.Used[name])
+			if free.Cmp(need) < 0 {
+				problems = append(problems, fmt.Sprintf("resource quota %s has %s of %s left, the jumpbox needs %s", quota.Name, free.String(), key, need.String()))
+			}
+		}
+	}
+	return problems
+}
+
+// createPermissions are the verbs create needs
+func createPermissions() []createPermission {
+	permissions := []createPermission{
+		{verb: "create", resource: "secrets"},
+		{verb: "create", resource: "persistentvolumeclaims"},
+		{verb: "get", resource: "services"},
+		{verb: "get", resource: "configmaps"},
+		{verb: "create", group: gvrVM.Group, resource: gvrVM.Resource},
+		{verb: "get", group: gvrVM.Group, resource: gvrVM.Resource},
+		{verb: "create", group: gvrSvc.Group, resource: gvrSvc.Resource},
+	}
+	if options.Bootstrap != bootstrapSecret {
+		permissions = append(permissions, createPermission{verb: "create", resource: "configmaps"})
+	}
+	return permissions
+}
+
+// permissionProblems checks the permissions of create with SelfSubjectAccessReviews
+func permissionProblems(ctx context.Context) []string {
+	var problems []string
+	for _, p := range createPermissions() {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: options.Namespace,
+					Verb:      p.verb,
+					Group:     p.group,
+					Resource:  p.resource,
+				},
+			},
+		}
+		res, err := c.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, v1.CreateOptions{})
+		if err != nil {
+			problems = append(problems, errors.Wrap(err, "error reviewing permissions").Error())
+			continue
+		}
+		if !res.Status.Allowed {
+			resource := p.resource
+			if p.group != "" {
+				resource += "." + p.group
+			}
+			problems = append(problems, fmt.Sprintf("not allowed to %s %s in namespace %s", p.verb, resource, options.Namespace))
+		}
+	}
+	return problems
+}
+
+func available(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
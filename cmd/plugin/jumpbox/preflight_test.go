@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	simpleFake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"strings"
+	"testing"
+)
+
+func Test_nameProblems(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{name: "jumpbox-1", want: 0},
+		{name: "Jumpbox_1", want: 7},
+		{name: "1-jumpbox", want: 1},
+		{name: strings.Repeat("j", 62), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{})
+			setup([]string{tt.name})
+			if got := nameProblems(); len(got) != tt.want {
+				t.Errorf("nameProblems() got %d problems, want %d: %v", len(got), tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_networkProblems(t *testing.T) {
+	tests := []struct {
+		name        string
+		networkType string
+		networkName string
+		wantErr     bool
+	}{
+		{name: "nsx-t", networkType: networkTypeNSXT},
+		{name: "vds-without-name", networkType: networkTypeVDS, wantErr: true},
+		{name: "invalid-type", networkType: "vlan", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{NetworkType: tt.networkType, NetworkName: tt.networkName})
+			if got := networkProblems(context.Background()); (len(got) > 0) != tt.wantErr {
+				t.Errorf("networkProblems() got %v, wantErr %v", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_quotaHeadroom(t *testing.T) {
+	setOptions(t, &VMOptions{
+		StorageClassName: "gold",
+		Disk:             DiskSettings{Size: "100Gi"},
+		Volumes:          []VolumeSettings{{Name: "scratch", Size: "50Gi", StorageClass: "silver"}},
+	})
+	requested := requestedResources(&ClassInfo{Name: "best-effort-large", CPUs: 4, Memory: "16Gi"})
+	quotas := []corev1.ResourceQuota{{
+		ObjectMeta: v1.ObjectMeta{Name: "ns-storagequota"},
+		Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{
+			"gold" + storageClassQuotaSuffix:   resource.MustParse("500Gi"),
+			"silver" + storageClassQuotaSuffix: resource.MustParse("100Gi"),
+			corev1.ResourceRequestsStorage:     resource.MustParse("1Ti"),
+			corev1.ResourceLimitsMemory:        resource.MustParse("32Gi"),
+			corev1.ResourceLimitsCPU:           resource.MustParse("8"),
+		}},
+		Status: corev1.ResourceQuotaStatus{Used: corev1.ResourceList{
+			"gold" + storageClassQuotaSuffix:   resource.MustParse("100Gi"),
+			"silver" + storageClassQuotaSuffix: resource.MustParse("80Gi"),
+			corev1.ResourceLimitsMemory:        resource.MustParse("24Gi"),
+		}},
+	}}
+
+	got := quotaHeadroom(quotas, requested)
+	if len(got) != 2 {
+		t.Fatalf("quotaHeadroom() got %v, want memory and silver storage problems", got)
+	}
+	for i, want := range []string{string(corev1.ResourceLimitsMemory), "silver" + storageClassQuotaSuffix} {
+		if !strings.Contains(got[i], want) {
+			t.Errorf("quotaHeadroom() problem %q, want %s", got[i], want)
+		}
+	}
+}
+
+func Test_permissionProblems(t *testing.T) {
+	fakeClient := simpleFake.NewSimpleClientset()
+	fakeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = !(attributes.Resource == gvrSvc.Resource && attributes.Verb == "create")
+		return true, review, nil
+	})
+	setClient(t, fakeClient)
+	setOptions(t, &VMOptions{Namespace: "test", Bootstrap: bootstrapSecret})
+
+	got := permissionProblems(context.Background())
+	want := "not allowed to create virtualmachineservices.vmoperator.vmware.com in namespace test"
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("permissionProblems() got %v, want [%s]", got, want)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+var profileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Profile is a reusable set of create settings. The named profiles and the defaults are stored in the plugin config
+// file. Create flags override the profile, and the profile overrides the defaults
+type Profile struct {
+	Namespace        string `json:"namespace,omitempty"`
+	ImageName        string `json:"image,omitempty"`
+	ClassName        string `json:"class,omitempty"`
+	StorageClassName string `json:"storageClass,omitempty"`
+	NetworkType      string `json:"networkType,omitempty"`
+	NetworkName      string `json:"networkName,omitempty"`
+	User             string `json:"user,omitempty"`
+	Template         string `json:"template,omitempty"`
+	DiskSize         string `json:"diskSize,omitempty"`
+	OS               string `json:"os,omitempty"`
+	CPUs             int    `json:"cpus,omitempty"`
+	Memory           string `json:"memory,omitempty"`
+}
+
+func validateProfileName(name string) error {
+	if !profileNameRegex.MatchString(name) {
+		return errors.Errorf("invalid profile name %q", name)
+	}
+	return nil
+}
+
+// createProfile is the profile of the current create options. The image and class resolved from --os, --cpus and
+// --memory are not saved, so the profile resolves them again
+func createProfile() *Profile {
+	profile := &Profile{
+		Namespace:        options.Namespace,
+		ImageName:        options.ImageName,
+		ClassName:        options.ClassName,
+		StorageClassName: options.StorageClassName,
+		NetworkType:      options.NetworkType,
+		NetworkName:      options.NetworkName,
+		User:             options.User,
+		Template:         options.Template,
+		DiskSize:         options.Disk.Size,
+		OS:               options.OS,
+		CPUs:             options.CPUs,
+		Memory:           options.Memory,
+	}
+	if profile.OS != "" {
+		profile.ImageName = ""
+	}
+	if profile.CPUs > 0 || profile.Memory != "" {
+		profile.ClassName = ""
+	}
+	return profile
+}
+
+// flags maps the create flags to the profile values
+func (p *Profile) flags() map[string]string {
+	cpus := ""
+	if p.CPUs > 0 {
+		cpus = strconv.Itoa(p.CPUs)
+	}
+	return map[string]string{
+		"namespace":     p.Namespace,
+		"image":         p.ImageName,
+		"class":         p.ClassName,
+		"storage-class": p.StorageClassName,
+		"network-type":  p.NetworkType,
+		"network-name":  p.NetworkName,
+		"user":          p.User,
+		"template":      p.Template,
+		"disk-size":     p.DiskSize,
+		"os":            p.OS,
+		"cpus":          cpus,
+		"memory":        p.Memory,
+	}
+}
+
+// applyTo sets the create flags not given from the profile. Profile values conflicting with the --image, --class,
+// --os, --cpus or --memory flags given are skipped
+func (p *Profile) applyTo(cmd *cobra.Command) error {
+	conflicts := map[string][]string{}
+	for flag, intents := range intentFlags {
+		conflicts[flag] = append(conflicts[flag], intents...)
+		for _, intent := range intents {
+			conflicts[intent] = append(conflicts[intent], flag)
+		}
+	}
+	given := func(name string) bool {
+		for _, other := range append([]string{name}, conflicts[name]...) {
+			if cmd.Flags().Changed(other) {
+				return true
+			}
+		}
+		return false
+	}
+
+	flags := p.flags()
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if flags[name] == "" || cmd.Flags().Lookup(name) == nil || given(name) {
+			continue
+		}
+		err := cmd.Flags().Set(name, flags[name])
+		if err != nil {
+			return errors.Wrap(err, "error applying profile")
+		}
+	}
+	return nil
+}
+
+func (config *PluginConfig) profile(name string) (*Profile, error) {
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return nil, errors.Errorf("profile %q not found. run `tanzu jumpbox profile list` to see the profiles", name)
+	}
+	return &profile, nil
+}
+
+func loadProfile(name string) (*Profile, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.profile(name)
+}
+
+func saveProfile(name string, profile *Profile) error {
+	err := validateProfileName(name)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]Profile{}
+	}
+	config.Profiles[name] = *profile
+	return saveConfig(config)
+}
+
+func newProfileCmd() *cobra.Command {
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage the create profiles of the plugin config file",
+	}
+	profileCmd.AddCommand(
+		newProfileCreateCmd(),
+		newProfileListCmd(),
+		newProfileUseCmd(),
+	)
+	return profileCmd
+}
+
+func newProfileCreateCmd() *cobra.Command {
+	profile := &Profile{}
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a profile from the create flags given",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := checkIntentFlags(cmd)
+			if err != nil {
+				return err
+			}
+			config, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if _, ok := config.Profiles[args[0]]; ok {
+				return errors.Errorf("profile %q already exists", args[0])
+			}
+			err = saveProfile(args[0], profile)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Created profile %s\n", args[0])
+			return nil
+		}}
+
+	createCmd.Flags().StringVarP(&profile.Namespace, "namespace", "n", "", "vm namespace")
+	createCmd.Flags().StringVarP(&profile.ImageName, "image", "i", "", "vm image from VM Service registered content library")
+	createCmd.Flags().StringVarP(&profile.ClassName, "class", "c", "", "vm class")
+	createCmd.Flags().StringVarP(&profile.StorageClassName, "storage-class", "", "", "vm storage class name")
+	createCmd.Flags().StringVarP(&profile.NetworkType, "network-type", "", "", "Network type. `nsx-t` or `vsphere-distributed`")
+	createCmd.Flags().StringVarP(&profile.NetworkName, "network-name", "", "", "vm network name")
+	createCmd.Flags().StringVarP(&profile.User, "user", "u", "", "User to be created in VM")
+	createCmd.Flags().StringVarP(&profile.Template, "template", "t", "", "Cloud-config template name")
+	createCmd.Flags().StringVarP(&profile.DiskSize, "disk-size", "", "", "Workspace disk size")
+	createCmd.Flags().StringVarP(&profile.OS, "os", "", "", "Use the newest image of `distro[:version]` instead of --image")
+	createCmd.Flags().IntVarP(&profile.CPUs, "cpus", "", 0, "Use the smallest class with at least these CPUs instead of --class")
+	createCmd.Flags().StringVarP(&profile.Memory, "memory", "", "", "Use the smallest class with at least this memory instead of --class")
+
+	return createCmd
+}
+
+func newProfileListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the profiles. The current profile is marked with *",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(config.Profiles))
+			for name := range config.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			_, _ = fmt.Fprintln(w, "CURRENT\tNAME\tNAMESPACE\tIMAGE\tCLASS\tSTORAGE CLASS")
+			for _, name := range names {
+				p := config.Profiles[name]
+				current := ""
+				if name == config.CurrentProfile {
+					current = "*"
+				}
+				image, class := p.ImageName, p.ClassName
+				if p.OS != "" {
+					image = "--os " + p.OS
+				}
+				if p.CPUs > 0 || p.Memory != "" {
+					class = classIntent(p.CPUs, p.Memory)
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", current, name, p.Namespace, image, class, p.StorageClassName)
+			}
+			return w.Flush()
+		}}
+}
+
+func newProfileUseCmd() *cobra.Command {
+	var none bool
+	useCmd := &cobra.Command{
+		Use:   "use",
+		Short: "Set the profile create uses without --profile",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if none {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if none {
+				config.CurrentProfile = ""
+				return saveConfig(config)
+			}
+			_, err = config.profile(args[0])
+			if err != nil {
+				return err
+			}
+			config.CurrentProfile = args[0]
+			err = saveConfig(config)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Using profile %s\n", args[0])
+			return nil
+		}}
+	useCmd.Flags().BoolVarP(&none, "none", "", false, "Unset the current profile")
+
+	return useCmd
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net"
+	"strings"
+	"time"
+)
+
+const sshDialTimeout = 10 * time.Second
+
+// loadBalancerIP returns the external ip of the jumpbox ssh service
+func loadBalancerIP(svc *corev1.Service) (string, error) {
+	if len(svc.Status.LoadBalancer.Ingress) == 0 {
+		return "", errors.Errorf("service %s has no load balancer ip yet", svc.Name)
+	}
+	return svc.Status.LoadBalancer.Ingress[0].IP, nil
+}
+
+// dialJumpbox opens an ssh connection to the jumpbox as user using the key stored in the jumpbox ssh secret
+func dialJumpbox(ctx context.Context, user string) (*ssh.Client, error) {
+	svc, err := c.CoreV1().Services(options.Namespace).Get(ctx, options.svcName, v1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting svc")
+	}
+	ip, err := loadBalancerIP(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := c.CoreV1().Secrets(options.Namespace).Get(ctx, options.sshSecretName, v1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting ssh secret")
+	}
+	signer, err := ssh.ParsePrivateKey(secret.Data["ssh-privatekey"])
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing private key")
+	}
+
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec // jumpbox host keys are generated on first boot
+		Timeout:         sshDialTimeout,
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(ip, "22"), config)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error connecting to %s@%s", config.User, ip))
+	}
+	return client, nil
+}
+
+// dialJumpboxAdmin opens an ssh connection to the jumpbox as the image default user to run privileged scripts
+func dialJumpboxAdmin(ctx context.Context) (*ssh.Client, error) {
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	admin, err := cloudUser(ctx, spec.ImageName, loadConfigOrEmpty())
+	if err != nil {
+		return nil, err
+	}
+	return dialJumpbox(ctx, admin)
+}
+
+// runRemote runs a shell script on the jumpbox, feeding stdin to it when set, and returns its output
+func runRemote(client *ssh.Client, script string, stdin io.Reader) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", errors.Wrap(err, "error opening ssh session")
+	}
+	defer func(session *ssh.Session) {
+		_ = session.Close()
+	}(session)
+
+	out := new(bytes.Buffer)
+	session.Stdout = out
+	session.Stderr = out
+	session.Stdin = stdin
+
+	err = session.Run(script)
+	if err != nil {
+		return out.String(), errors.Wrap(err, fmt.Sprintf("error running remote script: %s", strings.TrimSpace(out.String())))
+	}
+	return out.String(), nil
+}
+
+// postBoot runs the provisioning steps that need ssh access to the jumpbox after it boots
+func postBoot(ctx context.Context) error {
+	err := installOfflineTools(ctx)
+	if err != nil {
+		return err
+	}
+	return seedDotfiles(ctx)
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// annotationSpec records the resolved create options in the jumpbox VM
+const annotationSpec = "jumpbox.tanzu.vmware.com/spec"
+
+// specVersion is the version of JumpboxSpec written by this plugin
+const specVersion = "v1"
+
+// JumpboxSpec are the create options of a jumpbox, without secrets, persisted in the VM so later commands stop guessing
+type JumpboxSpec struct {
+	Version          string `json:"version"`
+	ImageName        string `json:"imageName"`
+	ClassName        string `json:"className"`
+	StorageClassName string `json:"storageClassName"`
+	NetworkType      string `json:"networkType"`
+	NetworkName      string `json:"networkName,omitempty"`
+	User             string `json:"user"`
+	Groups           string `json:"groups,omitempty"`
+	Shell            string `json:"shell,omitempty"`
+	Sudo             string `json:"sudo,omitempty"`
+	Template         string `json:"template,omitempty"`
+	// UserDataFile is the --user-data path, relative to the manifest or to the working directory of create
+	UserDataFile string `json:"userDataFile,omitempty"`
+	// UserDataTemplate is the --user-data template, so the jumpbox is rebuilt without the file
+	UserDataTemplate string                 `json:"userDataTemplate,omitempty"`
+	MergeUserData    bool                   `json:"mergeUserData,omitempty"`
+	Values           map[string]interface{} `json:"values,omitempty"`
+	// Tools are the names of the installed tools. Null uses the template default tools
+	Tools []string `json:"tools"`
+	// OfflineToolsDir is the --offline-tools path, relative to the manifest or to the working directory of create, and
+	// OfflineToolsSHA256 the hash of its content
+	OfflineToolsDir    string `json:"offlineToolsDir,omitempty"`
+	OfflineToolsSHA256 string `json:"offlineToolsSha256,omitempty"`
+	AptMirror          string `json:"aptMirror,omitempty"`
+	Transport          string `json:"transport,omitempty"`
+	Bootstrap          string `json:"bootstrap,omitempty"`
+	// SecretKeys are the keys of the secret values. Their values are only stored in the bootstrap Secret
+	SecretKeys []string `json:"secretKeys,omitempty"`
+	// DotfilesDir is the --dotfiles path, relative to the manifest or to the working directory of create, and
+	// DotfilesSHA256 the hash of its content
+	DotfilesDir    string           `json:"dotfilesDir,omitempty"`
+	DotfilesSHA256 string           `json:"dotfilesSha256,omitempty"`
+	Network        *NetworkSettings `json:"network,omitempty"`
+	// CACerts are the PEM certificates of the network CA certificate files, so the jumpbox is rebuilt without them
+	CACerts []string         `json:"caCerts,omitempty"`
+	Disk    *DiskSettings    `json:"disk,omitempty"`
+	Volumes []VolumeSettings `json:"volumes,omitempty"`
+	// OS, CPUs and Memory are the create flags the image and class were resolved from
+	OS     string        `json:"os,omitempty"`
+	CPUs   int           `json:"cpus,omitempty"`
+	Memory string        `json:"memory,omitempty"`
+	Ports  []ServicePort `json:"ports,omitempty"`
+	// NICs are the --nic network interfaces. The network type and name are the ones of the first interface
+	NICs []NetworkInterface `json:"nics,omitempty"`
+}
+
+// newJumpboxSpec builds the spec from the current options
+func newJumpboxSpec() *JumpboxSpec {
+	spec := &JumpboxSpec{
+		Version:            specVersion,
+		ImageName:          options.ImageName,
+		ClassName:          options.ClassName,
+		StorageClassName:   options.StorageClassName,
+		NetworkType:        options.NetworkType,
+		NetworkName:        options.NetworkName,
+		User:               options.User,
+		Groups:             options.Groups,
+		Shell:              options.Shell,
+		Sudo:               options.Sudo,
+		Template:           options.Template,
+		UserDataFile:       options.userDataPath,
+		UserDataTemplate:   options.userDataText,
+		MergeUserData:      options.mergeUserData,
+		Values:             options.Values,
+		Tools:              options.Tools,
+		OfflineToolsDir:    options.offlineToolsPath,
+		OfflineToolsSHA256: options.offlineToolsSHA256,
+		AptMirror:          options.AptMirror,
+		Transport:          options.Transport,
+		Bootstrap:          options.Bootstrap,
+		SecretKeys:         secretKeys(options.Secrets),
+		DotfilesDir:        options.dotfilesPath,
+		DotfilesSHA256:     options.dotfilesSHA256,
+		CACerts:            options.caCerts,
+		Volumes:            options.Volumes,
+		OS:                 options.OS,
+		CPUs:               options.CPUs,
+		Memory:             options.Memory,
+		Ports:              options.Ports,
+		NICs:               options.NICs,
+	}
+	if options.Disk != (DiskSettings{}) {
+		disk := options.Disk
+		spec.Disk = &disk
+	}
+	if !options.Network.empty() {
+		network := options.Network
+		spec.Network = &network
+	}
+	return spec
+}
+
+func (s *JumpboxSpec) String() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// applyTo sets the options not given as flags from the spec
+func (s *JumpboxSpec) applyTo(o *VMOptions) {
+	setDefault := func(value *string, def string) {
+		if *value == "" {
+			*value = def
+		}
+	}
+	setDefault(&o.ImageName, s.ImageName)
+	setDefault(&o.ClassName, s.ClassName)
+	setDefault(&o.StorageClassName, s.StorageClassName)
+	setDefault(&o.NetworkType, s.NetworkType)
+	setDefault(&o.NetworkName, s.NetworkName)
+	setDefault(&o.User, s.User)
+	setDefault(&o.Groups, s.Groups)
+	setDefault(&o.Shell, s.Shell)
+	setDefault(&o.Sudo, s.Sudo)
+	setDefault(&o.Template, s.Template)
+	setDefault(&o.offlineToolsPath, s.OfflineToolsDir)
+	setDefault(&o.AptMirror, s.AptMirror)
+	if o.dotfilesPath == "" {
+		o.dotfilesPath = s.DotfilesDir
+		o.dotfilesSHA256 = s.DotfilesSHA256
+	}
+	if s.Network != nil {
+		if len(o.Network.CACertFiles) == 0 {
+			o.caCerts = s.CACerts
+		}
+		o.Network.applyDefaults(*s.Network)
+	}
+	if s.Disk != nil {
+		setDefault(&o.Disk.Size, s.Disk.Size)
+		setDefault(&o.Disk.FSType, s.Disk.FSType)
+		setDefault(&o.Disk.MountPath, s.Disk.MountPath)
+	}
+	if o.Volumes == nil {
+		o.Volumes = s.Volumes
+	}
+	if o.Ports == nil {
+		o.Ports = s.Ports
+	}
+	if o.NICs == nil {
+		o.NICs = s.NICs
+	}
+	// jumpboxes created before --transport use OvfEnv
+	setDefault(&o.Transport, s.Transport)
+	setDefault(&o.Transport, transportOvfEnv)
+	setDefault(&o.Bootstrap, s.Bootstrap)
+	setDefault(&o.Bootstrap, bootstrapConfigMap)
+	if o.userDataPath == "" {
+		o.userDataPath = s.UserDataFile
+		o.userDataText = s.UserDataTemplate
+		o.mergeUserData = s.MergeUserData
+	}
+	o.Values = s.Values
+	if o.Tools == nil {
+		o.Tools = s.Tools
+	}
+}
+
+// loginUser returns the user to access the jumpbox when --user is not set
+func loginUser(ctx context.Context, spec *JumpboxSpec) (string, error) {
+	if options.User != "" {
+		return options.User, nil
+	}
+	if spec.User != "" {
+		return spec.User, nil
+	}
+	return cloudUser(ctx, spec.ImageName, loadConfigOrEmpty())
+}
+
+// getVM gets the jumpbox VirtualMachine
+func getVM(ctx context.Context) (*v1alpha1.VirtualMachine, error) {
+	res, err := dynamicClient.Resource(gvrVM).Namespace(options.Namespace).Get(ctx, options.Name, v1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting vm")
+	}
+	vm := &v1alpha1.VirtualMachine{}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, vm)
+	if err != nil {
+		return nil, errors.Wrap(err, "error converting vm")
+	}
+	return vm, nil
+}
+
+// getJumpboxSpec reads the spec persisted in the jumpbox VM.
+// Jumpboxes created by older plugin versions have their spec derived from the VM. Reading doesn't write it back, the
+// commands that change the VM, like update, rebuild and apply, persist it with their changes
+func getJumpboxSpec(ctx context.Context) (*JumpboxSpec, error) {
+	vm, err := getVM(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := vm.Annotations[annotationSpec]
+	if !ok {
+		return legacySpec(vm), nil
+	}
+
+	spec := &JumpboxSpec{}
+	err = json.Unmarshal([]byte(data), spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing jumpbox spec")
+	}
+	return migrateSpec(spec)
+}
+
+// legacySpec derives the spec of a jumpbox created before the spec was persisted.
+// Those versions always provisioned the `operator` user with passwordless sudo and the dev tools
+func legacySpec(vm *v1alpha1.VirtualMachine) *JumpboxSpec {
+	spec := &JumpboxSpec{
+		Version:          specVersion,
+		ImageName:        vm.Spec.ImageName,
+		ClassName:        vm.Spec.ClassName,
+		StorageClassName: vm.Spec.StorageClass,
+		User:             defaultUser,
+		Groups:           defaultGroups,
+		Shell:            defaultShell,
+		Sudo:             sudoNoPasswd,
+		Template:         defaultTemplate,
+	}
+	if len(vm.Spec.NetworkInterfaces) > 0 {
+		spec.NetworkType = vm.Spec.NetworkInterfaces[0].NetworkType
+		spec.NetworkName = vm.Spec.NetworkInterfaces[0].NetworkName
+	}
+	if vm.Spec.VmMetadata != nil {
+		spec.Transport = string(vm.Spec.VmMetadata.Transport)
+		spec.Bootstrap = bootstrapConfigMap
+	}
+	return spec
+}
+
+// migrateSpec upgrades a spec written by an older plugin version
+func migrateSpec(spec *JumpboxSpec) (*JumpboxSpec, error) {
+	switch spec.Version {
+	case specVersion:
+		return spec, nil
+	default:
+		return nil, errors.Errorf("unsupported jumpbox spec version %q, upgrade the plugin", spec.Version)
+	}
+}
+
+// patchJumpboxSpec persists the spec in the VM, merging vmSpec into the VirtualMachine spec
+func patchJumpboxSpec(ctx context.Context, spec *JumpboxSpec, vmSpec map[string]interface{}) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				annotationSpec: spec.String(),
+			},
+		},
+	}
+	if vmSpec != nil {
+		patch["spec"] = vmSpec
+	}
+	payload, err := json.Marshal(patch)
+	if err != nil {
+		return errors.Wrap(err, "err marshaling")
+	}
+	_, err = dynamicClient.Resource(gvrVM).Namespace(options.Namespace).Patch(ctx, options.Name, types.MergePatchType, payload, v1.PatchOptions{})
+	if err != nil {
+		return errors.Wrap(err, "err patching")
+	}
+	return nil
+}
+
+func newDescribeCmd(ctx context.Context) *cobra.Command {
+	describeCmd := &cobra.Command{
+		Use:   "describe",
+		Short: "Describe Jumpbox",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Describe(ctx)
+		}}
+	describeCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	_ = describeCmd.MarkFlagRequired("namespace")
+
+	return describeCmd
+}
+
+func newRebuildCmd(ctx context.Context) *cobra.Command {
+	rebuildCmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Recreate the Jumpbox VM from its spec, keeping the persistent volume and ssh keys",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Rebuild(ctx)
+		}}
+	rebuildCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	addUserDataFlags(rebuildCmd)
+	_ = rebuildCmd.MarkFlagRequired("namespace")
+
+	return rebuildCmd
+}
+
+func newUpdateCmd(ctx context.Context) *cobra.Command {
+	updateCmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update Jumpbox",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Update(ctx)
+		}}
+	updateCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	updateCmd.Flags().StringVarP(&options.ClassName, "class", "c", "", "vm class")
+	_ = updateCmd.MarkFlagRequired("namespace")
+
+	return updateCmd
+}
+
+// Describe prints the jumpbox spec and status
+func Describe(ctx context.Context) error {
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return err
+	}
+	vm, err := getVM(ctx)
+	if err != nil {
+		return err
+	}
+	lbIP := ""
+	svc, err := c.CoreV1().Services(options.Namespace).Get(ctx, options.svcName, v1.GetOptions{})
+	if err == nil {
+		lbIP, _ = loadBalancerIP(svc)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Name:\t%s\n", options.Name)
+	_, _ = fmt.Fprintf(w, "Namespace:\t%s\n", options.Namespace)
+	_, _ = fmt.Fprintf(w, "Power State:\t%s\n", vm.Status.PowerState)
+	_, _ = fmt.Fprintf(w, "VM IP:\t%s\n", vm.Status.VmIp)
+	_, _ = fmt.Fprintf(w, "Load Balancer IP:\t%s\n", lbIP)
+	image, class := spec.ImageName, spec.ClassName
+	if spec.OS != "" {
+		image += " (--os " + spec.OS + ")"
+	}
+	if spec.CPUs > 0 || spec.Memory != "" {
+		class += " (" + classIntent(spec.CPUs, spec.Memory) + ")"
+	}
+	_, _ = fmt.Fprintf(w, "Image:\t%s\n", image)
+	_, _ = fmt.Fprintf(w, "Class:\t%s\n", class)
+	_, _ = fmt.Fprintf(w, "Storage Class:\t%s\n", spec.StorageClassName)
+	_, _ = fmt.Fprintf(w, "Network:\t%s %s\n", spec.NetworkType, spec.NetworkName)
+	for i, nic := range spec.NICs {
+		address := "dhcp"
+		if nic.IP != "" {
+			address = strings.TrimSpace(nic.IP + " " + nic.Gateway)
+		}
+		_, _ = fmt.Fprintf(w, "NIC %d:\t%s %s %s\n", i, nic.Type, nic.Name, address)
+	}
+	_, _ = fmt.Fprintf(w, "User:\t%s\n", spec.User)
+	_, _ = fmt.Fprintf(w, "Sudo:\t%s\n", spec.Sudo)
+	if spec.Disk != nil {
+		_, _ = fmt.Fprintf(w, "Disk:\t%s %s on %s\n", spec.Disk.Size, spec.Disk.FSType, spec.Disk.MountPath)
+	}
+	for _, volume := range spec.Volumes {
+		_, _ = fmt.Fprintf(w, "Volume %s:\t%s %s on %s (%s)\n", volume.Name, volume.Size, volume.FSType, volume.MountPath, volume.StorageClass)
+	}
+	_, _ = fmt.Fprintf(w, "Transport:\t%s\n", spec.Transport)
+	_, _ = fmt.Fprintf(w, "Bootstrap:\t%s\n", spec.Bootstrap)
+	_, _ = fmt.Fprintf(w, "Template:\t%s\n", spec.Template)
+	_, _ = fmt.Fprintf(w, "Tools:\t%s\n", strings.Join(spec.Tools, ", "))
+	return w.Flush()
+}
+
+// Rebuild deletes and recreates the jumpbox VM from its spec. The persistent volume and ssh keys are kept
+func Rebuild(ctx context.Context) error {
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return err
+	}
+	spec.applyTo(options)
+
+	err = loadSSHKeys(ctx)
+	if err != nil {
+		return err
+	}
+	options.AccessUsers, err = getAccessUsers(ctx)
+	if err != nil {
+		return err
+	}
+	err = buildUserdata()
+	if err != nil {
+		return err
+	}
+	err = checkSecretKeys(spec.SecretKeys)
+	if err != nil {
+		return err
+	}
+	err = resolveBootstrap(ctx)
+	if err != nil {
+		return err
+	}
+	err = validateOfflineTools()
+	if err != nil {
+		return err
+	}
+	return recreateVM(ctx)
+}
+
+// loadSSHKeys reads the jumpbox ssh keys, so a recreated VM keeps them
+func loadSSHKeys(ctx context.Context) error {
+	secret, err := c.CoreV1().Secrets(options.Namespace).Get(ctx, options.sshSecretName, v1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "error getting ssh secret")
+	}
+	options.SSHPublicKey = string(secret.Data["ssh-publickey"])
+	options.SSHPrivateKey = string(secret.Data["ssh-privatekey"])
+	return nil
+}
+
+// recreateVM deletes the jumpbox VM and creates it again, with its bootstrap data, from the current options
+func recreateVM(ctx context.Context) error {
+	err := dynamicClient.Resource(gvrVM).Namespace(options.Namespace).Delete(ctx, options.Name, v1.DeleteOptions{})
+	if err != nil {
+		return errors.Wrap(err, "error deleting VM")
+	}
+	err = waitDeleted(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Deleted Virtual Machine %s\n", options.Name)
+
+	err = deleteBootstrap(ctx)
+	if err != nil {
+		return err
+	}
+	err = createBootstrap(ctx)
+	if err != nil {
+		return err
+	}
+	err = createVM(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = waitCreate(ctx)
+	if err != nil {
+		return err
+	}
+	return postBoot(ctx)
+}
+
+// Update changes the jumpbox VM class. The new class is applied on the next power cycle
+func Update(ctx context.Context) error {
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return err
+	}
+	if options.ClassName == "" || options.ClassName == spec.ClassName {
+		fmt.Println("Nothing to update")
+		return nil
+	}
+	spec.ClassName = options.ClassName
+
+	err = patchJumpboxSpec(ctx, spec, map[string]interface{}{
+		"className": spec.ClassName,
+	})
+	if err != nil {
+		return errors.WithMessage(err, "error updating vm class")
+	}
+	fmt.Printf("Updated Virtual Machine %s class to %s. Power cycle the jumpbox to apply it\n", options.Name, spec.ClassName)
+	return nil
+}
+
+// waitDeleted waits for the jumpbox VM to be removed
+func waitDeleted(ctx context.Context) error {
+	fmt.Print("waiting for VM to be deleted ")
+	for {
+		_, err := dynamicClient.Resource(gvrVM).Namespace(options.Namespace).Get(ctx, options.Name, v1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "error getting vm")
+		}
+		fmt.Print(".")
+		time.Sleep(5 * time.Second)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1/install"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+	"reflect"
+	"testing"
+)
+
+func Test_getJumpboxSpec(t *testing.T) {
+	ctx := context.Background()
+
+	legacyVM := &v1alpha1.VirtualMachine{
+		TypeMeta: v1.TypeMeta{
+			Kind:       "VirtualMachine",
+			APIVersion: "vmoperator.vmware.com/v1alpha1",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "legacy",
+			Namespace: "test",
+		},
+		Spec: v1alpha1.VirtualMachineSpec{
+			ImageName:    "centos-stream-8",
+			ClassName:    "best-effort-small",
+			StorageClass: "test",
+			NetworkInterfaces: []v1alpha1.VirtualMachineNetworkInterface{{
+				NetworkType: "vsphere-distributed",
+				NetworkName: "workload",
+			}},
+		},
+	}
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	setDynamicClient(t, fake.NewSimpleDynamicClient(scheme, legacyVM))
+
+	tests := []struct {
+		name    string
+		vmName  string
+		create  *VMOptions
+		want    JumpboxSpec
+		wantErr bool
+	}{
+		{
+			name:   "created-with-spec",
+			vmName: "jumpbox-1",
+			create: &VMOptions{
+				Namespace:        "test",
+				ImageName:        "ubuntu-20",
+				ClassName:        "best-effort-large",
+				StorageClassName: "test",
+				NetworkType:      "nsx-t",
+				User:             "alice",
+				Sudo:             sudoNone,
+			},
+			want: JumpboxSpec{
+				Version:          specVersion,
+				ImageName:        "ubuntu-20",
+				ClassName:        "best-effort-large",
+				StorageClassName: "test",
+				NetworkType:      "nsx-t",
+				User:             "alice",
+				Sudo:             sudoNone,
+			},
+		},
+		{
+			name:   "legacy-derived",
+			vmName: "legacy",
+			want: JumpboxSpec{
+				Version:          specVersion,
+				ImageName:        "centos-stream-8",
+				ClassName:        "best-effort-small",
+				StorageClassName: "test",
+				NetworkType:      "vsphere-distributed",
+				NetworkName:      "workload",
+				User:             "operator",
+				Groups:           "sudo",
+				Shell:            "/bin/bash",
+				Sudo:             sudoNoPasswd,
+				Template:         defaultTemplate,
+			},
+		},
+		{
+			name:    "not-found",
+			vmName:  "jumpbox-2",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{Namespace: "test"})
+			if tt.create != nil {
+				setOptions(t, tt.create)
+			}
+			setup([]string{tt.vmName})
+			if tt.create != nil {
+				if err := createVM(ctx); err != nil {
+					t.Fatalf("createVM() error = %v", err)
+				}
+			}
+
+			got, err := getJumpboxSpec(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getJumpboxSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("getJumpboxSpec() got %+v, want %+v", *got, tt.want)
+			}
+
+			vm, err := getVM(ctx)
+			if err != nil {
+				t.Fatalf("getVM() error = %v", err)
+			}
+			// only create persists the spec, reading a legacy jumpbox leaves it unchanged
+			if _, ok := vm.Annotations[annotationSpec]; ok != (tt.create != nil) {
+				t.Errorf("getJumpboxSpec() spec annotation persisted = %v, want %v", ok, tt.create != nil)
+			}
+		})
+	}
+}
+
+func TestJumpboxSpec_applyTo(t *testing.T) {
+	spec := &JumpboxSpec{
+		UserDataFile:     "user-data.yaml",
+		UserDataTemplate: "#cloud-config\n",
+		MergeUserData:    true,
+		DotfilesDir:      "dotfiles",
+		DotfilesSHA256:   "abc",
+		Network:          &NetworkSettings{},
+		CACerts:          []string{"-----BEGIN CERTIFICATE-----"},
+	}
+	o := &VMOptions{}
+	spec.applyTo(o)
+	if o.userDataPath != "user-data.yaml" || o.userDataText != "#cloud-config\n" || !o.mergeUserData {
+		t.Errorf("applyTo() user data = %q %q %v", o.userDataPath, o.userDataText, o.mergeUserData)
+	}
+	if o.dotfilesPath != "dotfiles" || o.dotfilesSHA256 != "abc" {
+		t.Errorf("applyTo() dotfiles = %q %q", o.dotfilesPath, o.dotfilesSHA256)
+	}
+	if !reflect.DeepEqual(o.caCerts, spec.CACerts) {
+		t.Errorf("applyTo() caCerts = %v, want %v", o.caCerts, spec.CACerts)
+	}
+
+	// files given on the command line win over the recorded content
+	o = &VMOptions{userDataPath: "other.yaml"}
+	o.Network.CACertFiles = []string{"ca.pem"}
+	spec.applyTo(o)
+	if o.userDataText != "" || o.caCerts != nil {
+		t.Errorf("applyTo() kept recorded content: %q %v", o.userDataText, o.caCerts)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+	"text/template"
+)
+
+// sysprepUnattendKey is the VM metadata key of the Sysprep answer file
+const sysprepUnattendKey = "unattend"
+
+// maxComputerNameLength is the longest windows computer name (NetBIOS name)
+const maxComputerNameLength = 15
+
+// maxSysprepCommandLength is the longest Path of a RunSynchronousCommand
+const maxSysprepCommandLength = 259
+
+// sysprepAuthorizedKeys is the file OpenSSH for windows reads the keys of the Administrators members from
+const sysprepAuthorizedKeys = `C:\ProgramData\ssh\administrators_authorized_keys`
+
+// sysprepKeyCommand appends a chunk of the public key to the authorized keys file, without a newline
+const sysprepKeyCommand = `powershell.exe -NoProfile -Command "Add-Content -NoNewline -Encoding ascii -Path ` + sysprepAuthorizedKeys + ` -Value '%s'"`
+
+// sysprepTemplate is the answer file. The specialize pass names the computer and runs the commands that install sshd
+// and create the user, the oobeSystem pass skips the welcome screens
+var sysprepTemplate = template.Must(template.New("unattend").Funcs(template.FuncMap{
+	"xml": xmlText,
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<?xml version="1.0" encoding="utf-8"?>
+<unattend xmlns="urn:schemas-microsoft-com:unattend" xmlns:wcm="http://schemas.microsoft.com/WMIConfig/2002/State">
+  <settings pass="specialize">
+    <component name="Microsoft-Windows-Shell-Setup" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <ComputerName>{{ xml .Name }}</ComputerName>
+    </component>
+    <component name="Microsoft-Windows-Deployment" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <RunSynchronous>
+{{- range $i, $command := .Commands }}
+        <RunSynchronousCommand wcm:action="add">
+          <Order>{{ inc $i }}</Order>
+          <Path>{{ xml $command }}</Path>
+        </RunSynchronousCommand>
+{{- end }}
+      </RunSynchronous>
+    </component>
+  </settings>
+  <settings pass="oobeSystem">
+    <component name="Microsoft-Windows-Shell-Setup" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <OOBE>
+        <HideEULAPage>true</HideEULAPage>
+        <HideLocalAccountScreen>true</HideLocalAccountScreen>
+        <HideOnlineAccountScreens>true</HideOnlineAccountScreens>
+        <HideWirelessSetupInOOBE>true</HideWirelessSetupInOOBE>
+        <ProtectYourPC>3</ProtectYourPC>
+        <SkipMachineOOBE>true</SkipMachineOOBE>
+        <SkipUserOOBE>true</SkipUserOOBE>
+      </OOBE>
+    </component>
+  </settings>
+</unattend>
+`))
+
+// xmlText escapes s for XML character data
+func xmlText(s string) (string, error) {
+	var b strings.Builder
+	if err := xml.EscapeText(&b, []byte(s)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// validateComputerName checks the jumpbox name can be the windows computer name
+func validateComputerName(name string) error {
+	if len(name) > maxComputerNameLength {
+		return errors.Errorf("name %s is longer than %d characters, the limit of windows computer names for the Sysprep transport", name, maxComputerNameLength)
+	}
+	return nil
+}
+
+// sysprepCommands installs and starts the OpenSSH server, authorizes the jumpbox key and creates the user as a member
+// of Administrators with a random password, since the user logs in with the key
+func sysprepCommands(user string, publicKey string) ([]string, error) {
+	fields := strings.Fields(publicKey)
+	if len(fields) < 2 {
+		return nil, errors.New("invalid ssh public key")
+	}
+	key := fields[0] + " " + fields[1]
+
+	password, err := sysprepPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	commands := []string{
+		`powershell.exe -NoProfile -Command "Add-WindowsCapability -Online -Name OpenSSH.Server~~~~0.0.1.0"`,
+		`sc.exe config sshd start= auto`,
+		`net.exe start sshd`,
+		`cmd.exe /c if exist ` + sysprepAuthorizedKeys + ` del ` + sysprepAuthorizedKeys,
+	}
+	chunk := maxSysprepCommandLength - len(sysprepKeyCommand)
+	for len(key) > 0 {
+		n := chunk
+		if n > len(key) {
+			n = len(key)
+		}
+		commands = append(commands, fmt.Sprintf(sysprepKeyCommand, key[:n]))
+		key = key[n:]
+	}
+	commands = append(commands,
+		`icacls.exe `+sysprepAuthorizedKeys+` /inheritance:r /grant Administrators:F /grant SYSTEM:F`,
+		fmt.Sprintf(`net.exe user %s %s /add`, user, password),
+		fmt.Sprintf(`net.exe localgroup Administrators %s /add`, user),
+	)
+	return commands, nil
+}
+
+// sysprepPassword generates a password meeting the windows complexity rules
+func sysprepPassword() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "error generating password")
+	}
+	return base64.RawURLEncoding.EncodeToString(b) + "aA1", nil
+}
+
+// sysprepUnattend renders the answer file of the jumpbox
+func sysprepUnattend() (string, error) {
+	err := validateComputerName(options.Name)
+	if err != nil {
+		return "", err
+	}
+	commands, err := sysprepCommands(options.User, options.SSHPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	err = sysprepTemplate.Execute(&b, struct {
+		Name     string
+		Commands []string
+	}{Name: options.Name, Commands: commands})
+	if err != nil {
+		return "", errors.Wrap(err, "error rendering unattend")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func Test_sysprepUnattend(t *testing.T) {
+	key, pub, err := MakeSSHKeyPair()
+	if err != nil || len(key) == 0 {
+		t.Fatalf("MakeSSHKeyPair() error = %v", err)
+	}
+	setOptions(t, &VMOptions{Name: "win-jumpbox", User: "alice", SSHPublicKey: string(pub) + " alice@laptop"})
+
+	got, err := sysprepUnattend()
+	if err != nil {
+		t.Fatalf("sysprepUnattend() error = %v", err)
+	}
+
+	var unattend struct {
+		Settings []struct {
+			Pass       string `xml:"pass,attr"`
+			Components []struct {
+				ComputerName string   `xml:"ComputerName"`
+				Commands     []string `xml:"RunSynchronous>RunSynchronousCommand>Path"`
+			} `xml:"component"`
+		} `xml:"settings"`
+	}
+	if err := xml.Unmarshal([]byte(got), &unattend); err != nil {
+		t.Fatalf("unattend is not well-formed: %v\n%s", err, got)
+	}
+	if len(unattend.Settings) != 2 || unattend.Settings[0].Pass != "specialize" || unattend.Settings[1].Pass != "oobeSystem" {
+		t.Fatalf("unattend settings got %+v, want specialize and oobeSystem", unattend.Settings)
+	}
+	specialize := unattend.Settings[0].Components
+	if specialize[0].ComputerName != "win-jumpbox" {
+		t.Errorf("ComputerName got %q, want win-jumpbox", specialize[0].ComputerName)
+	}
+
+	authorizedKey := ""
+	for _, command := range specialize[1].Commands {
+		if len(command) > maxSysprepCommandLength {
+			t.Errorf("command is longer than %d characters: %s", maxSysprepCommandLength, command)
+		}
+		if strings.Contains(command, "Add-Content") {
+			authorizedKey += command[strings.Index(command, "-Value '")+len("-Value '") : strings.LastIndex(command, "'")]
+		}
+	}
+	fields := strings.Fields(string(pub))
+	if authorizedKey != fields[0]+" "+fields[1] {
+		t.Errorf("authorized key got %q, want %q", authorizedKey, fields[0]+" "+fields[1])
+	}
+	if !strings.Contains(got, "net.exe localgroup Administrators alice /add") {
+		t.Errorf("unattend does not add alice to Administrators:\n%s", got)
+	}
+}
+
+func Test_validateComputerName(t *testing.T) {
+	if err := validateComputerName("jumpbox-123456"); err != nil {
+		t.Errorf("validateComputerName() error = %v", err)
+	}
+	if err := validateComputerName("jumpbox-12345678"); err == nil {
+		t.Errorf("validateComputerName() want error for 16 characters")
+	}
+}
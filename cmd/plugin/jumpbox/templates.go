@@ -0,0 +1,169 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+//go:embed templates/*.yaml templates/partials.tpl
+var templatesFS embed.FS
+
+// defaultTemplate is the template used by create when --template is not set
+const defaultTemplate = "dev"
+
+const templateExt = ".yaml"
+
+// userdataTemplate is a named cloud-config template, built-in or from ~/.tanzu/jumpbox/templates
+type userdataTemplate struct {
+	Name   string
+	Source string
+	Text   string
+}
+
+func userTemplatesDir() string {
+	return filepath.Join(options.tanzuDir, "templates")
+}
+
+// listTemplates returns the built-in and user templates by name. User templates override built-in ones
+func listTemplates() (map[string]userdataTemplate, error) {
+	templates := map[string]userdataTemplate{}
+
+	entries, err := templatesFS.ReadDir("templates")
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading built-in templates")
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != templateExt {
+			continue
+		}
+		data, err := templatesFS.ReadFile("templates/" + entry.Name())
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading built-in template")
+		}
+		name := strings.TrimSuffix(entry.Name(), templateExt)
+		templates[name] = userdataTemplate{Name: name, Source: "built-in", Text: string(data)}
+	}
+
+	entries, err = os.ReadDir(userTemplatesDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "error reading user templates")
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != templateExt {
+			continue
+		}
+		path := filepath.Join(userTemplatesDir(), entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading user template")
+		}
+		name := strings.TrimSuffix(entry.Name(), templateExt)
+		templates[name] = userdataTemplate{Name: name, Source: path, Text: string(data)}
+	}
+	return templates, nil
+}
+
+// getTemplate returns the template by name
+func getTemplate(name string) (userdataTemplate, error) {
+	templates, err := listTemplates()
+	if err != nil {
+		return userdataTemplate{}, err
+	}
+	t, ok := templates[name]
+	if !ok {
+		return userdataTemplate{}, errors.Errorf("template %q not found. available templates: %s", name, strings.Join(templateNames(templates), ", "))
+	}
+	return t, nil
+}
+
+func templateNames(templates map[string]userdataTemplate) []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templatePartials are the shared `{{ define }}` blocks available to every template
+func templatePartials() string {
+	data, err := templatesFS.ReadFile("templates/partials.tpl")
+	if err != nil {
+		panic(errors.Wrap(err, "error reading template partials"))
+	}
+	return string(data)
+}
+
+func newTemplateCmd() *cobra.Command {
+	templateCmd := &cobra.Command{
+		Use:   "template",
+		Short: "Manage cloud-config templates",
+	}
+	templateCmd.AddCommand(
+		newTemplateListCmd(),
+		newTemplateShowCmd(),
+		newTemplateRenderCmd(),
+	)
+	return templateCmd
+}
+
+func newTemplateListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List built-in and user templates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			templates, err := listTemplates()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			_, _ = fmt.Fprintln(w, "NAME\tSOURCE")
+			for _, name := range templateNames(templates) {
+				_, _ = fmt.Fprintf(w, "%s\t%s\n", name, templates[name].Source)
+			}
+			return w.Flush()
+		}}
+}
+
+func newTemplateShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Print a template source",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t, err := getTemplate(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Print(t.Text)
+			return nil
+		}}
+}
+
+func newTemplateRenderCmd() *cobra.Command {
+	renderCmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render a template with the create options",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.Template = args[0]
+			options.SSHPublicKey = placeholderSSHPublicKey
+			doc, err := renderUserdataDoc()
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(doc))
+			return nil
+		}}
+	addUserdataTemplateFlags(renderCmd)
+
+	return renderCmd
+}
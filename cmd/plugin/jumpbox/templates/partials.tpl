@@ -0,0 +1,31 @@
+{{- /* partials shared by the built-in templates. user templates can use them too */ -}}
+
+{{- define "users" -}}
+ssh_authorized_keys:
+  - {{ .SSHPublicKey }}
+
+users:
+  - default
+  - name: {{ .User }}
+    groups: '{{ .Groups }}'
+    shell: '{{ .Shell }}'
+{{- if eq .Sudo "nopasswd" }}
+    sudo: ['ALL=(ALL) NOPASSWD:ALL']
+{{- else if eq .Sudo "password" }}
+    sudo: ['ALL=(ALL) ALL']
+{{- end }}
+    ssh-authorized-keys:
+      - {{ .SSHPublicKey }}
+{{- range .AccessUsers }}
+  - name: {{ .Name }}
+    shell: /bin/bash
+    ssh_authorized_keys:
+{{- range .Keys }}
+      - {{ . }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- /* the workspace disk is mounted by the plugin in every template. kept for user templates still using it */ -}}
+{{- define "workspace" -}}
+{{- end }}
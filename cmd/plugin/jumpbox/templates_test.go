@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_getTemplate(t *testing.T) {
+	setOptions(t, &VMOptions{tanzuDir: t.TempDir()})
+	err := os.MkdirAll(userTemplatesDir(), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(userTemplatesDir(), "minimal.yaml"), []byte("#cloud-config\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(userTemplatesDir(), "team.yaml"), []byte("#cloud-config\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		template   string
+		wantSource string
+		wantErr    bool
+	}{
+		{
+			name:       "built-in",
+			template:   "k8s-admin",
+			wantSource: "built-in",
+		},
+		{
+			name:       "user-overrides-built-in",
+			template:   "minimal",
+			wantSource: filepath.Join(userTemplatesDir(), "minimal.yaml"),
+		},
+		{
+			name:       "user",
+			template:   "team",
+			wantSource: filepath.Join(userTemplatesDir(), "team.yaml"),
+		},
+		{
+			name:     "not-found",
+			template: "windows",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getTemplate(tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Source != tt.wantSource {
+				t.Errorf("getTemplate() source = %s, want %s", got.Source, tt.wantSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+//go:generate go run pintools.go
+//go:embed manifests/tools.yaml
+var builtinToolManifest []byte
+
+// toolsScriptPath is where the generated install script is written in the jumpbox
+const toolsScriptPath = "/usr/local/sbin/jumpbox-tools.sh"
+
+// toolsStateDir records the version of each installed tool in the jumpbox
+const toolsStateDir = "/var/lib/jumpbox/tools"
+
+// templateToolsRegex reads the default tools of a template from its `# tools: a,b` header line
+var templateToolsRegex = regexp.MustCompile(`(?m)^# tools:(.*)$`)
+
+// Tool is a tool installed in the jumpbox from a versioned, checksum verified download
+type Tool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	// SHA256 pins the checksum of the download. When empty, it is read from ChecksumURL, only for the tools of the
+	// user manifest. The built-in tools are pinned by go generate
+	SHA256      string `json:"sha256,omitempty"`
+	ChecksumURL string `json:"checksumUrl,omitempty"`
+	// Binary is the file name of the tool inside a .tar.gz download
+	Binary         string `json:"binary,omitempty"`
+	InstallPath    string `json:"installPath"`
+	VersionCommand string `json:"versionCommand,omitempty"`
+
+	builtin bool
+}
+
+// ToolManifest lists the tools that can be installed in the jumpbox
+type ToolManifest struct {
+	Tools []Tool `json:"tools"`
+}
+
+func userToolManifestPath() string {
+	return filepath.Join(options.tanzuDir, "tools.yaml")
+}
+
+// loadToolManifest reads the built-in manifest with the tools of ~/.tanzu/jumpbox/tools.yaml added or overridden by name.
+// The built-in tools are validated when they are selected, so the manifest can be listed before their sha256 is pinned
+func loadToolManifest() (map[string]Tool, error) {
+	manifest := &ToolManifest{}
+	err := yaml.Unmarshal(builtinToolManifest, manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing built-in tool manifest")
+	}
+
+	userManifest := &ToolManifest{}
+	data, err := os.ReadFile(userToolManifestPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "error reading tool manifest")
+	}
+	if err == nil {
+		err = yaml.Unmarshal(data, userManifest)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing tool manifest")
+		}
+	}
+
+	tools := map[string]Tool{}
+	for _, tool := range manifest.Tools {
+		tool.builtin = true
+		tools[tool.Name] = tool
+	}
+	for _, tool := range userManifest.Tools {
+		err = validateTool(tool)
+		if err != nil {
+			return nil, err
+		}
+		tools[tool.Name] = tool
+	}
+	return tools, nil
+}
+
+// validateTool checks the tool can be installed. Built-in tools must pin their sha256, the checksumUrl of the release
+// is only read at install time for the tools of the user manifest
+func validateTool(tool Tool) error {
+	switch {
+	case tool.Name == "" || tool.Version == "" || tool.URL == "" || tool.InstallPath == "":
+		return errors.Errorf("tool %q must have name, version, url and installPath", tool.Name)
+	case tool.builtin && tool.SHA256 == "":
+		return errors.Errorf("built-in tool %q has no pinned sha256. run go generate to pin it, or pin it in %s", tool.Name, userToolManifestPath())
+	case tool.SHA256 == "" && tool.ChecksumURL == "":
+		return errors.Errorf("tool %q must have sha256 or checksumUrl", tool.Name)
+	case isArchive(tool.URL) && tool.Binary == "":
+		return errors.Errorf("tool %q is an archive and must have binary", tool.Name)
+	}
+	return nil
+}
+
+func isArchive(url string) bool {
+	return strings.HasSuffix(url, ".tar.gz") || strings.HasSuffix(url, ".tgz")
+}
+
+// templateTools returns the default tools declared in the template header
+func templateTools(text string) []string {
+	match := templateToolsRegex.FindStringSubmatch(text)
+	if match == nil {
+		return nil
+	}
+	return splitList(match[1])
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// selectTools resolves the tool names against the manifest
+func selectTools(names []string) ([]Tool, error) {
+	manifest, err := loadToolManifest()
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]Tool, 0, len(names))
+	for _, name := range names {
+		tool, ok := manifest[name]
+		if !ok {
+			return nil, errors.Errorf("tool %q not found in the tool manifest", name)
+		}
+		err = validateTool(tool)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+var toolsScriptTemplate = template.Must(template.New("tools").Funcs(template.FuncMap{"quote": shellQuote}).Parse(`#!/bin/bash
+# Installs the jumpbox tools. Generated by the tanzu jumpbox plugin from the tool manifest
+set -uo pipefail
+
+# cloud-init doesn't load the proxy settings of /etc/environment
+if [ -f /etc/environment ]; then
+  set -a
+  . /etc/environment
+  set +a
+fi
+
+state_dir={{ quote .StateDir }}
+work_dir=$(mktemp -d)
+trap 'rm -rf "$work_dir"' EXIT
+mkdir -p "$state_dir"
+
+# download <url> <file> reads the file from JUMPBOX_TOOLS_DIR, when set, for offline installs
+download() {
+  if [ -n "${JUMPBOX_TOOLS_DIR:-}" ]; then
+    cp "$JUMPBOX_TOOLS_DIR/$(basename "$1")" "$2"
+    return
+  fi
+  curl -fsSL --retry 5 --retry-delay 3 -o "$2" "$1"
+}
+
+# expected_sha256 <file name> <sha256> <checksum url>
+expected_sha256() {
+  if [ -n "$2" ]; then
+    echo "$2"
+    return
+  fi
+  download "$3" "$work_dir/checksums" || return 1
+  if [ "$(wc -w < "$work_dir/checksums")" -eq 1 ]; then
+    cat "$work_dir/checksums"
+    return
+  fi
+  awk -v f="$1" '$2 == f || $2 == "*"f || $2 ~ "/"f"$" { print $1; exit }' "$work_dir/checksums"
+}
+
+# install_tool <name> <version> <url> <sha256> <checksum url> <binary> <install path>
+install_tool() {
+  local name=$1 version=$2 url=$3 sha256=$4 checksum_url=$5 binary=$6 install_path=$7
+  if [ "$(cat "$state_dir/$name" 2>/dev/null)" = "$version" ]; then
+    echo "$name $version is already installed"
+    return
+  fi
+
+  local file
+  file="$work_dir/$(basename "$url")"
+  download "$url" "$file" || return 1
+  local expected
+  expected=$(expected_sha256 "$(basename "$url")" "$sha256" "$checksum_url") || return 1
+  if [ -z "$expected" ]; then
+    echo "no checksum found for $name" >&2
+    return 1
+  fi
+  if ! echo "$expected  $file" | sha256sum --check --quiet; then
+    echo "checksum mismatch for $name" >&2
+    return 1
+  fi
+
+  case "$file" in
+    *.tar.gz|*.tgz)
+      mkdir -p "$work_dir/$name" && tar -xzf "$file" -C "$work_dir/$name" || return 1
+      file=$(find "$work_dir/$name" -type f -name "$binary" | head -n 1)
+      if [ -z "$file" ]; then
+        echo "$binary not found in $name archive" >&2
+        return 1
+      fi
+      ;;
+  esac
+
+  install -D -m 0755 "$file" "$install_path" || return 1
+  echo "$version" > "$state_dir/$name"
+  echo "installed $name $version"
+}
+
+failed=""
+{{- range .Tools }}
+install_tool {{ quote .Name }} {{ quote .Version }} {{ quote .URL }} {{ quote .SHA256 }} {{ quote .ChecksumURL }} {{ quote .Binary }} {{ quote .InstallPath }} || failed="$failed {{ .Name }}"
+{{- end }}
+
+if [ -n "$failed" ]; then
+  echo "failed to install:$failed" >&2
+  exit 1
+fi
+`))
+
+// shellQuote single quotes s for bash
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// renderToolsScript renders the install script of the tools
+func renderToolsScript(tools []Tool) (string, error) {
+	buf := new(bytes.Buffer)
+	err := toolsScriptTemplate.Execute(buf, map[string]interface{}{
+		"StateDir": toolsStateDir,
+		"Tools":    tools,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "err building tools script")
+	}
+	return buf.String(), nil
+}
+
+// toolsCloudConfig is the cloud-config, merged over the template, that writes and runs the install script of the tools.
+// Offline installs only write the script, it is run after the tools are uploaded
+func toolsCloudConfig(names []string, offline bool) ([]byte, error) {
+	tools, err := selectTools(names)
+	if err != nil {
+		return nil, err
+	}
+	script, err := renderToolsScript(tools)
+	if err != nil {
+		return nil, err
+	}
+	toolsConfig := map[string]interface{}{
+		"write_files": []interface{}{
+			map[string]interface{}{
+				"path":        toolsScriptPath,
+				"permissions": "0755",
+				"content":     script,
+			},
+		},
+	}
+	if !offline {
+		toolsConfig["runcmd"] = []interface{}{toolsScriptPath}
+	}
+	config, err := yaml.Marshal(toolsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling tools cloud-config")
+	}
+	return append([]byte(cloudConfigHeader+"\n"), config...), nil
+}
+
+func newToolsCmd(ctx context.Context) *cobra.Command {
+	toolsCmd := &cobra.Command{
+		Use:   "tools",
+		Short: "Manage the tools installed in the Jumpbox",
+	}
+	toolsCmd.AddCommand(
+		newToolsListCmd(),
+		newToolsStatusCmd(ctx),
+	)
+	return toolsCmd
+}
+
+func newToolsListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the tools of the tool manifest",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, err := loadToolManifest()
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(manifest))
+			for name := range manifest {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			_, _ = fmt.Fprintln(w, "NAME\tVERSION\tINSTALL PATH")
+			for _, name := range names {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", name, manifest[name].Version, manifest[name].InstallPath)
+			}
+			return w.Flush()
+		}}
+}
+
+func newToolsStatusCmd(ctx context.Context) *cobra.Command {
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Report the versions of the tools installed in the Jumpbox",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			setup(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ToolsStatus(ctx)
+		}}
+	statusCmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	_ = statusCmd.MarkFlagRequired("namespace")
+
+	return statusCmd
+}
+
+// ToolsStatus compares the tools recorded in the jumpbox spec with the versions installed, read over ssh
+func ToolsStatus(ctx context.Context) error {
+	spec, err := getJumpboxSpec(ctx)
+	if err != nil {
+		return err
+	}
+	manifest, err := loadToolManifest()
+	if err != nil {
+		return err
+	}
+
+	user, err := loginUser(ctx, spec)
+	if err != nil {
+		return err
+	}
+	client, err := dialJumpbox(ctx, user)
+	if err != nil {
+		return err
+	}
+	defer func(client *ssh.Client) {
+		_ = client.Close()
+	}(client)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TOOL\tMANIFEST\tINSTALLED\tSTATUS\tVERSION")
+	for _, name := range spec.Tools {
+		tool := manifest[name]
+		installed, _ := runRemote(client, fmt.Sprintf("cat %s", shellQuote(toolsStateDir+"/"+name)), nil)
+		installed = strings.TrimSpace(installed)
+
+		status := "ok"
+		switch {
+		case installed == "" || strings.Contains(installed, "No such file"):
+			installed = "-"
+			status = "missing"
+		case tool.Version == "":
+			status = "unknown"
+		case installed != tool.Version:
+			status = "outdated"
+		}
+
+		version := "-"
+		if tool.VersionCommand != "" {
+			out, err := runRemote(client, tool.VersionCommand+" 2>&1 | head -n 1", nil)
+			if err == nil {
+				version = strings.TrimSpace(out)
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, tool.Version, installed, status, version)
+	}
+	return w.Flush()
+}
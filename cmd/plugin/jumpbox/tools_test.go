@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_loadToolManifest(t *testing.T) {
+	tests := []struct {
+		name        string
+		manifest    string
+		wantVersion string
+		wantErr     bool
+	}{
+		{
+			name:        "built-in",
+			wantVersion: "v1.27.4",
+		},
+		{
+			name:        "override",
+			manifest:    "tools:\n  - name: kubectl\n    version: v1.28.0\n    url: https://dl.k8s.io/kubectl\n    sha256: abc\n    installPath: /usr/local/bin/kubectl\n",
+			wantVersion: "v1.28.0",
+		},
+		{
+			name:     "missing-checksum",
+			manifest: "tools:\n  - name: kubectl\n    version: v1.28.0\n    url: https://dl.k8s.io/kubectl\n    installPath: /usr/local/bin/kubectl\n",
+			wantErr:  true,
+		},
+		{
+			name:     "archive-missing-binary",
+			manifest: "tools:\n  - name: k9s\n    version: v0.27.4\n    url: https://example.com/k9s.tar.gz\n    sha256: abc\n    installPath: /usr/local/bin/k9s\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{tanzuDir: t.TempDir()})
+			if tt.manifest != "" {
+				err := os.WriteFile(filepath.Join(options.tanzuDir, "tools.yaml"), []byte(tt.manifest), 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := loadToolManifest()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadToolManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got["kubectl"].Version != tt.wantVersion {
+				t.Errorf("loadToolManifest() kubectl version = %s, want %s", got["kubectl"].Version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func Test_templateTools(t *testing.T) {
+	got := templateTools("#cloud-config\n# tools: kubectl, helm,,jq\npackages: []\n")
+	if strings.Join(got, ",") != "kubectl,helm,jq" {
+		t.Errorf("templateTools() got %v", got)
+	}
+	if got := templateTools("#cloud-config\n"); got != nil {
+		t.Errorf("templateTools() got %v, want nil", got)
+	}
+}
+
+func Test_toolsCloudConfig(t *testing.T) {
+	setOptions(t, &VMOptions{tanzuDir: t.TempDir()})
+	pinBuiltinTools(t)
+	doc, err := toolsCloudConfig([]string{"kubectl", "tanzu"}, false)
+	if err != nil {
+		t.Fatalf("toolsCloudConfig() error = %v", err)
+	}
+	if err := validateUserData(doc); err != nil {
+		t.Errorf("toolsCloudConfig() is invalid: %v", err)
+	}
+	for _, want := range []string{toolsScriptPath, "sha256sum --check", "install_tool 'kubectl' 'v1.27.4'", "'tanzu-cli-linux_amd64'"} {
+		if !strings.Contains(string(doc), want) {
+			t.Errorf("toolsCloudConfig() missing %q", want)
+		}
+	}
+
+	_, err = toolsCloudConfig([]string{"unknown"}, false)
+	if err == nil {
+		t.Errorf("toolsCloudConfig() want error for unknown tool")
+	}
+}
+
+func Test_validateTool(t *testing.T) {
+	tool := Tool{Name: "kubectl", Version: "v1.27.4", URL: "https://dl.k8s.io/kubectl", InstallPath: "/usr/local/bin/kubectl",
+		ChecksumURL: "https://dl.k8s.io/kubectl.sha256"}
+	if err := validateTool(tool); err != nil {
+		t.Errorf("validateTool() of a user tool with checksumUrl error = %v", err)
+	}
+	tool.builtin = true
+	if err := validateTool(tool); err == nil {
+		t.Errorf("validateTool() want error for a built-in tool without sha256")
+	}
+	tool.SHA256 = strings.Repeat("0", 64)
+	if err := validateTool(tool); err != nil {
+		t.Errorf("validateTool() of a pinned built-in tool error = %v", err)
+	}
+}
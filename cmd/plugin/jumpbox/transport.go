@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"strings"
+)
+
+// transports supported by --transport. auto selects the transport from the image. Sysprep is not a transport of the
+// v1alpha1 VM Service API, supervisors with a VM Operator that supports it take the windows answer file
+const (
+	transportAuto        = "auto"
+	transportOvfEnv      = string(v1alpha1.VirtualMachineMetadataOvfEnvTransport)
+	transportExtraConfig = string(v1alpha1.VirtualMachineMetadataExtraConfigTransport)
+	transportCloudInit   = string(v1alpha1.VirtualMachineMetadataCloudInitTransport)
+	transportSysprep     = "Sysprep"
+)
+
+var transports = []string{transportAuto, transportOvfEnv, transportExtraConfig, transportCloudInit, transportSysprep}
+
+// ovfUserDataKey is the OVF property of cloud images that takes the base64 user data
+const ovfUserDataKey = "user-data"
+
+// resolveTransport validates --transport against the image, selecting it when auto. OvfEnv needs the image to have a
+// user-data OVF property, ExtraConfig and CloudInit need cloud-init in a linux guest and Sysprep a windows guest
+func resolveTransport(ctx context.Context) error {
+	if options.Transport == "" {
+		options.Transport = transportAuto
+	}
+	found := false
+	for _, t := range transports {
+		if strings.EqualFold(options.Transport, t) {
+			options.Transport = t
+			found = true
+		}
+	}
+	if !found {
+		return errors.Errorf("invalid transport %q. supported transports: %s", options.Transport, strings.Join(transports, ", "))
+	}
+
+	image, err := getVMImage(ctx, options.ImageName)
+	if err != nil {
+		return errors.Wrap(err, "error getting vm image")
+	}
+	_, ovfUserData := image.Spec.OVFEnv[ovfUserDataKey]
+	windows := strings.Contains(imageOS(image), "windows")
+
+	switch {
+	case options.Transport == transportAuto && windows:
+		options.Transport = transportSysprep
+	case options.Transport == transportAuto && ovfUserData:
+		options.Transport = transportOvfEnv
+	case options.Transport == transportAuto:
+		options.Transport = transportCloudInit
+	}
+
+	switch {
+	case options.Transport == transportSysprep && !windows:
+		return errors.Errorf("transport %s needs a windows image and image %s is %s", transportSysprep, options.ImageName, imageOS(image))
+	case options.Transport == transportSysprep:
+		return validateComputerName(options.Name)
+	case windows:
+		return errors.Errorf("transport %s needs cloud-init and image %s is windows. use --transport %s", options.Transport, options.ImageName, transportSysprep)
+	case options.Transport == transportOvfEnv && !ovfUserData:
+		return errors.Errorf("image %s has no %s OVF property for the OvfEnv transport. use --transport CloudInit or ExtraConfig", options.ImageName, ovfUserDataKey)
+	}
+	return nil
+}
+
+// metadataTransport is the transport of the jumpbox, OvfEnv when it is not set like for jumpboxes created before
+// --transport
+func metadataTransport() string {
+	if options.Transport == "" {
+		return transportOvfEnv
+	}
+	return options.Transport
+}
+
+// metadataData formats the bootstrap data for the transport. OvfEnv sets the image OVF properties, ExtraConfig the
+// cloud-init guestinfo keys, CloudInit takes the raw user data and Sysprep the windows answer file
+func metadataData() (map[string]string, error) {
+	switch metadataTransport() {
+	case transportOvfEnv:
+		return map[string]string{
+			ovfUserDataKey: options.UserData,
+			"hostname":     options.Name,
+		}, nil
+	case transportExtraConfig:
+		metadata := fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", options.Name, options.Name)
+		return map[string]string{
+			"guestinfo.userdata":          options.UserData,
+			"guestinfo.userdata.encoding": "base64",
+			"guestinfo.metadata":          base64.StdEncoding.EncodeToString([]byte(metadata)),
+			"guestinfo.metadata.encoding": "base64",
+		}, nil
+	case transportCloudInit:
+		doc, err := base64.StdEncoding.DecodeString(options.UserData)
+		if err != nil {
+			return nil, errors.Wrap(err, "error decoding user data")
+		}
+		return map[string]string{
+			"user-data": string(doc),
+			"hostname":  options.Name,
+		}, nil
+	case transportSysprep:
+		unattend, err := sysprepUnattend()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{sysprepUnattendKey: unattend}, nil
+	default:
+		return nil, errors.Errorf("unsupported transport %q", options.Transport)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1/install"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+func Test_resolveTransport(t *testing.T) {
+	ctx := context.Background()
+
+	image := func(name string, osType string, ovfEnv map[string]v1alpha1.OvfProperty) runtime.Object {
+		return &v1alpha1.VirtualMachineImage{
+			TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineImage", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec: v1alpha1.VirtualMachineImageSpec{
+				OSInfo: v1alpha1.VirtualMachineImageOSInfo{Type: osType},
+				OVFEnv: ovfEnv,
+			},
+		}
+	}
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	setDynamicClient(t, fake.NewSimpleDynamicClient(scheme,
+		image("ubuntu-ova", "ubuntu64Guest", map[string]v1alpha1.OvfProperty{ovfUserDataKey: {Key: ovfUserDataKey}}),
+		image("photon-cloud", "vmwarePhoton64Guest", nil),
+		image("windows-2019", "windows9Server64Guest", nil),
+	))
+
+	tests := []struct {
+		name      string
+		image     string
+		transport string
+		vmName    string
+		want      string
+		wantErr   bool
+	}{
+		{name: "auto-ovf", image: "ubuntu-ova", transport: transportAuto, want: transportOvfEnv},
+		{name: "auto-cloud-init", image: "photon-cloud", transport: transportAuto, want: transportCloudInit},
+		{name: "explicit", image: "ubuntu-ova", transport: "extraconfig", want: transportExtraConfig},
+		{name: "ovf-without-property", image: "photon-cloud", transport: transportOvfEnv, wantErr: true},
+		{name: "windows-sysprep", image: "windows-2019", transport: transportAuto, vmName: "win-jumpbox", want: transportSysprep},
+		{name: "windows-long-name", image: "windows-2019", transport: transportAuto, vmName: "windows-jumpbox-1", wantErr: true},
+		{name: "windows-cloud-init", image: "windows-2019", transport: transportCloudInit, wantErr: true},
+		{name: "linux-sysprep", image: "photon-cloud", transport: "sysprep", wantErr: true},
+		{name: "invalid", image: "ubuntu-ova", transport: "vapp", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{Name: tt.vmName, ImageName: tt.image, Transport: tt.transport})
+			err := resolveTransport(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && options.Transport != tt.want {
+				t.Errorf("resolveTransport() got %s, want %s", options.Transport, tt.want)
+			}
+		})
+	}
+}
+
+func Test_metadataData(t *testing.T) {
+	userData := base64.StdEncoding.EncodeToString([]byte("#cloud-config\n"))
+	tests := []struct {
+		transport string
+		key       string
+		want      string
+	}{
+		{transport: transportOvfEnv, key: "user-data", want: userData},
+		{transport: "", key: "user-data", want: userData},
+		{transport: transportExtraConfig, key: "guestinfo.userdata", want: userData},
+		{transport: transportExtraConfig, key: "guestinfo.userdata.encoding", want: "base64"},
+		{transport: transportCloudInit, key: "user-data", want: "#cloud-config\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transport+"/"+tt.key, func(t *testing.T) {
+			setOptions(t, &VMOptions{Name: "jumpbox-1", UserData: userData, Transport: tt.transport})
+			got, err := metadataData()
+			if err != nil {
+				t.Fatalf("metadataData() error = %v", err)
+			}
+			if got[tt.key] != tt.want {
+				t.Errorf("metadataData()[%s] got %q, want %q", tt.key, got[tt.key], tt.want)
+			}
+		})
+	}
+}
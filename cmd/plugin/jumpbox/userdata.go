@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"text/template"
+)
+
+const cloudConfigHeader = "#cloud-config"
+
+// addUserDataFlags adds the flags to customize the jumpbox cloud-init user data
+func addUserDataFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&options.Template, "template", "t", "", "Cloud-config template name. Run `tanzu jumpbox template list` to see available templates. Defaults to `"+defaultTemplate+"`")
+	cmd.Flags().StringVarP(&options.userDataPath, "user-data", "", "", "Path to a cloud-config or shell script template used as user data")
+	cmd.Flags().StringVarP(&options.valuesPath, "values", "", "", "Path to a yaml file with custom template values")
+	cmd.Flags().StringArrayVarP(&options.setValues, "set", "", nil, "Set a custom template value, key=value. Can be repeated")
+	addSecretValuesFlags(cmd)
+	cmd.Flags().BoolVarP(&options.mergeUserData, "merge", "", false, "Merge --user-data over the --template cloud-config instead of replacing it")
+	cmd.Flags().StringSliceVarP(&options.Tools, "tools", "", nil, "Comma separated tools to install from the tool manifest. Defaults to the template tools. Run `tanzu jumpbox tools list` to see available tools")
+	cmd.Flags().StringVarP(&options.offlineToolsPath, "offline-tools", "", "", "Path to a directory with the tool downloads, verified against the pinned sha256 of each tool. They are uploaded and installed over ssh after boot, for supervisors without internet access")
+	cmd.Flags().StringVarP(&options.AptMirror, "apt-mirror", "", "", "Apt mirror url used to install packages")
+	addNetworkFlags(cmd)
+	cmd.Flags().StringVarP(&options.dotfilesPath, "dotfiles", "", "", "Path to a directory seeded in the user home. Its install.sh, if any, is run after seeding")
+}
+
+// placeholderSSHPublicKey is rendered instead of the jumpbox key by the offline userdata commands
+const placeholderSSHPublicKey = "ssh-rsa GENERATED-ON-CREATE"
+
+func newUserdataCmd() *cobra.Command {
+	userdataCmd := &cobra.Command{
+		Use:   "userdata",
+		Short: "Render and validate user data templates offline",
+	}
+	userdataCmd.AddCommand(
+		newUserdataRenderCmd(),
+		newUserdataValidateCmd(),
+	)
+	return userdataCmd
+}
+
+func newUserdataRenderCmd() *cobra.Command {
+	renderCmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render a user data template with the create options",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			doc, err := renderUserdataFile(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(doc))
+			return nil
+		}}
+	addUserdataTemplateFlags(renderCmd)
+	renderCmd.Flags().StringVarP(&options.Template, "template", "t", defaultTemplate, "Cloud-config template to merge the user data over")
+
+	return renderCmd
+}
+
+func newUserdataValidateCmd() *cobra.Command {
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Render a user data template and validate it against the cloud-config schema",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			doc, err := renderUserdataFile(args[0])
+			if err != nil {
+				return err
+			}
+			err = validateUserData(doc)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s is valid\n", args[0])
+			return nil
+		}}
+	addUserdataTemplateFlags(validateCmd)
+	validateCmd.Flags().StringVarP(&options.Template, "template", "t", defaultTemplate, "Cloud-config template to merge the user data over")
+
+	return validateCmd
+}
+
+// addUserdataTemplateFlags adds the create options used by templates to the offline userdata commands
+func addUserdataTemplateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&options.Name, "name", "", "jumpbox", "Jumpbox name")
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "vm namespace")
+	cmd.Flags().StringVarP(&options.User, "user", "u", defaultUser, "User to be created in VM")
+	cmd.Flags().StringVarP(&options.Groups, "groups", "", defaultGroups, "Comma separated groups of the user")
+	cmd.Flags().StringVarP(&options.Shell, "shell", "", defaultShell, "Login shell of the user")
+	cmd.Flags().StringVarP(&options.Sudo, "sudo", "", sudoNoPasswd, "Sudo policy of the user. `none`, `password` or `nopasswd`")
+	cmd.Flags().StringVarP(&options.valuesPath, "values", "", "", "Path to a yaml file with custom template values")
+	cmd.Flags().StringArrayVarP(&options.setValues, "set", "", nil, "Set a custom template value, key=value. Can be repeated")
+	addSecretValuesFlags(cmd)
+	cmd.Flags().BoolVarP(&options.mergeUserData, "merge", "", false, "Merge the user data over the --template cloud-config instead of replacing it")
+	cmd.Flags().StringSliceVarP(&options.Tools, "tools", "", nil, "Comma separated tools to install from the tool manifest. Defaults to the template tools")
+	cmd.Flags().StringVarP(&options.AptMirror, "apt-mirror", "", "", "Apt mirror url used to install packages")
+	addNetworkFlags(cmd)
+	cmd.Flags().StringVarP(&options.dotfilesPath, "dotfiles", "", "", "Path to a directory seeded in the user home. Its install.sh, if any, is run after seeding")
+}
+
+// renderUserdataFile renders a user data template without a cluster, with a placeholder ssh key
+func renderUserdataFile(path string) ([]byte, error) {
+	options.userDataPath = path
+	options.SSHPublicKey = placeholderSSHPublicKey
+	return renderUserdataDoc()
+}
+
+// renderTemplate renders a userdata template with the VMOptions fields. Values are available as `.Values.<key>`
+// and the shared partials, like `{{ template "users" . }}`, can be used
+func renderTemplate(name string, text string) ([]byte, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(templatePartials())
+	if err != nil {
+		return nil, errors.Wrap(err, "err parsing template partials")
+	}
+	t, err = t.Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("err parsing %s template", name))
+	}
+
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, options)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("err building %s template", name))
+	}
+	return buf.Bytes(), nil
+}
+
+// renderUserData renders the user supplied --user-data file. With --merge, it is merged over base
+func renderUserData(base []byte) ([]byte, error) {
+	if options.userDataText == "" {
+		text, err := os.ReadFile(localPath(options.userDataPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading user data file")
+		}
+		options.userDataText = string(text)
+	}
+	user, err := renderTemplate(options.userDataPath, options.userDataText)
+	if err != nil {
+		return nil, err
+	}
+	if !options.mergeUserData {
+		return user, nil
+	}
+
+	if isCloudConfig(user) {
+		return mergeCloudConfig(base, user)
+	}
+	return multipartUserData(base, user)
+}
+
+func isCloudConfig(doc []byte) bool {
+	return strings.HasPrefix(strings.TrimSpace(string(doc)), cloudConfigHeader)
+}
+
+// mergeCloudConfig deep merges override over base with the cloud-init `dict(recurse_array)+list(append)+str()`
+// semantics: maps are merged recursively, lists are appended and scalars are replaced
+func mergeCloudConfig(base []byte, override []byte) ([]byte, error) {
+	baseConfig := map[string]interface{}{}
+	err := yaml.Unmarshal(base, &baseConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing base cloud-config")
+	}
+	overrideConfig := map[string]interface{}{}
+	err = yaml.Unmarshal(override, &overrideConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing user cloud-config")
+	}
+
+	merged, err := yaml.Marshal(mergeValues(baseConfig, overrideConfig))
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling merged cloud-config")
+	}
+	return append([]byte(cloudConfigHeader+"\n"), merged...), nil
+}
+
+func mergeValues(base interface{}, override interface{}) interface{} {
+	switch o := override.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			return o
+		}
+		merged := make(map[string]interface{}, len(b)+len(o))
+		for k, v := range b {
+			merged[k] = v
+		}
+		for k, v := range o {
+			merged[k] = mergeValues(b[k], v)
+		}
+		return merged
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			return o
+		}
+		return append(append([]interface{}{}, b...), o...)
+	default:
+		return o
+	}
+}
+
+// multipartUserData combines the base cloud-config with a user part that is not a cloud-config, like a shell script,
+// in a MIME multipart document that cloud-init runs in order
+func multipartUserData(base []byte, user []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := multipart.NewWriter(buf)
+	_, _ = fmt.Fprintf(buf, "Content-Type: multipart/mixed; boundary=\"%s\"\nMIME-Version: 1.0\n\n", w.Boundary())
+
+	parts := []struct {
+		contentType string
+		data        []byte
+	}{
+		{contentType: "text/cloud-config", data: base},
+		{contentType: partContentType(user), data: user},
+	}
+	for i, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", fmt.Sprintf("%s; charset=\"us-ascii\"", part.contentType))
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"part-%d\"", i))
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating multipart user data")
+		}
+		_, err = pw.Write(part.data)
+		if err != nil {
+			return nil, errors.Wrap(err, "error writing multipart user data")
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "error closing multipart user data")
+	}
+	return buf.Bytes(), nil
+}
+
+// partContentType returns the cloud-init content type of a user data part from its first line
+func partContentType(doc []byte) string {
+	switch {
+	case strings.HasPrefix(string(doc), "#!"):
+		return "text/x-shellscript"
+	case strings.HasPrefix(string(doc), "#cloud-boothook"):
+		return "text/cloud-boothook"
+	case strings.HasPrefix(string(doc), "#include"):
+		return "text/x-include-url"
+	default:
+		return "text/plain"
+	}
+}
+
+// loadValues builds the template values from the jumpbox spec, the --values file and --set flags, --set taking precedence.
+// Dotted keys in --set, like `git.user=alice`, set nested values
+func loadValues() (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for k, v := range options.Values {
+		values[k] = v
+	}
+	if options.valuesPath != "" {
+		data, err := os.ReadFile(localPath(options.valuesPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading values file")
+		}
+		fileValues := map[string]interface{}{}
+		err = yaml.Unmarshal(data, &fileValues)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing values file")
+		}
+		values = mergeValues(values, fileValues).(map[string]interface{})
+	}
+
+	for _, set := range options.setValues {
+		err := setValue(values, set)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// setValue sets a key=value in values. Dotted keys set nested values
+func setValue(values map[string]interface{}, set string) error {
+	kv := strings.SplitN(set, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return errors.Errorf("invalid value %q, expected key=value", set)
+	}
+	keys := strings.Split(kv[0], ".")
+	current := values
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+		current = next
+	}
+	current[keys[len(keys)-1]] = kv[1]
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"testing"
+)
+
+func Test_mergeCloudConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		override string
+		want     map[string]interface{}
+	}{
+		{
+			name:     "lists-appended",
+			base:     "#cloud-config\npackages:\n  - git\n",
+			override: "#cloud-config\npackages:\n  - jq\n",
+			want: map[string]interface{}{
+				"packages": []interface{}{"git", "jq"},
+			},
+		},
+		{
+			name:     "maps-recursive-scalars-replaced",
+			base:     "#cloud-config\napt:\n  preserve_sources_list: true\n  sources: {}\nrepo_update: true\n",
+			override: "#cloud-config\napt:\n  preserve_sources_list: false\nrepo_update: false\n",
+			want: map[string]interface{}{
+				"apt": map[string]interface{}{
+					"preserve_sources_list": false,
+					"sources":               map[string]interface{}{},
+				},
+				"repo_update": false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := mergeCloudConfig([]byte(tt.base), []byte(tt.override))
+			if err != nil {
+				t.Fatalf("mergeCloudConfig() error = %v", err)
+			}
+			if !isCloudConfig(merged) {
+				t.Errorf("mergeCloudConfig() missing %s header", cloudConfigHeader)
+			}
+			got := map[string]interface{}{}
+			if err := yaml.Unmarshal(merged, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeCloudConfig() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_multipartUserData(t *testing.T) {
+	doc, err := multipartUserData([]byte("#cloud-config\npackages: [git]\n"), []byte("#!/bin/bash\necho hello\n"))
+	if err != nil {
+		t.Fatalf("multipartUserData() error = %v", err)
+	}
+	for _, want := range []string{"Content-Type: multipart/mixed", "text/cloud-config", "text/x-shellscript", "echo hello"} {
+		if !strings.Contains(string(doc), want) {
+			t.Errorf("multipartUserData() missing %q", want)
+		}
+	}
+}
+
+func Test_loadValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     map[string]interface{}
+		valuesPath string
+		set        []string
+		want       map[string]interface{}
+		wantErr    bool
+	}{
+		{
+			name: "set",
+			set:  []string{"team=platform", "git.user=alice"},
+			want: map[string]interface{}{
+				"team": "platform",
+				"git":  map[string]interface{}{"user": "alice"},
+			},
+		},
+		{
+			name:   "set-overrides-spec",
+			values: map[string]interface{}{"team": "apps", "env": "dev"},
+			set:    []string{"team=platform"},
+			want:   map[string]interface{}{"team": "platform", "env": "dev"},
+		},
+		{
+			name:       "file-relative-to-manifest",
+			valuesPath: "values.yaml",
+			set:        []string{"team=platform"},
+			want:       map[string]interface{}{"team": "platform", "env": "prod"},
+		},
+		{
+			name:    "invalid",
+			set:     []string{"team"},
+			wantErr: true,
+		},
+	}
+	baseDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(baseDir, "values.yaml"), []byte("team: apps\nenv: prod\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{Values: tt.values, setValues: tt.set, valuesPath: tt.valuesPath, baseDir: baseDir})
+			got, err := loadValues()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadValues() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
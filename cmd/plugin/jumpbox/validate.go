@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"sigs.k8s.io/yaml/goyaml.v3"
+	"sort"
+	"strings"
+)
+
+// cloudConfigKeys are the top level keys supported by cloud-init modules, with the yaml kind expected for them.
+// Keys with a zero kind accept any value
+var cloudConfigKeys = map[string]yaml.Kind{
+	"apk_repos": yaml.MappingNode, "apt": yaml.MappingNode, "apt_pipelining": 0, "apt_preserve_sources_list": 0,
+	"apt_reboot_if_required": 0, "apt_update": 0, "apt_upgrade": 0, "autoinstall": yaml.MappingNode,
+	"bootcmd": yaml.SequenceNode, "byobu_by_default": yaml.ScalarNode, "ca_certs": yaml.MappingNode,
+	"ca-certs": yaml.MappingNode, "chef": yaml.MappingNode, "chpasswd": yaml.MappingNode,
+	"cloud_config_modules": yaml.SequenceNode, "cloud_final_modules": yaml.SequenceNode,
+	"cloud_init_modules": yaml.SequenceNode, "create_hostname_file": yaml.ScalarNode, "datasource": yaml.MappingNode,
+	"device_aliases": yaml.MappingNode, "disable_ec2_metadata": yaml.ScalarNode, "disable_root": yaml.ScalarNode,
+	"disable_root_opts": yaml.ScalarNode, "disk_setup": yaml.MappingNode, "drivers": yaml.MappingNode,
+	"fan": yaml.MappingNode, "final_message": yaml.ScalarNode, "fqdn": yaml.ScalarNode, "fs_setup": yaml.SequenceNode,
+	"groups": 0, "growpart": yaml.MappingNode, "hostname": yaml.ScalarNode, "keyboard": yaml.MappingNode,
+	"landscape": yaml.MappingNode, "locale": yaml.ScalarNode, "locale_configfile": yaml.ScalarNode,
+	"lxd": yaml.MappingNode, "manage_etc_hosts": yaml.ScalarNode, "manage_resolv_conf": yaml.ScalarNode,
+	"mcollective": yaml.MappingNode, "merge_how": 0, "merge_type": 0, "migrate": yaml.ScalarNode,
+	"mount_default_fields": yaml.SequenceNode, "mounts": yaml.SequenceNode, "network": yaml.MappingNode,
+	"no_ssh_fingerprints": yaml.ScalarNode, "ntp": yaml.MappingNode, "output": yaml.MappingNode,
+	"package_reboot_if_required": yaml.ScalarNode, "package_update": yaml.ScalarNode,
+	"package_upgrade": yaml.ScalarNode, "packages": yaml.SequenceNode, "password": yaml.ScalarNode,
+	"phone_home": yaml.MappingNode, "power_state": yaml.MappingNode, "prefer_fqdn_over_hostname": yaml.ScalarNode,
+	"preserve_hostname": yaml.ScalarNode, "puppet": yaml.MappingNode, "random_seed": yaml.MappingNode,
+	"reporting": yaml.MappingNode, "repo_update": yaml.ScalarNode, "repo_upgrade": yaml.ScalarNode,
+	"repo_upgrade_exclude": yaml.SequenceNode, "resize_rootfs": yaml.ScalarNode, "resolv_conf": yaml.MappingNode,
+	"rh_subscription": yaml.MappingNode, "rsyslog": 0, "runcmd": yaml.SequenceNode, "salt_minion": yaml.MappingNode,
+	"snap": yaml.MappingNode, "spacewalk": yaml.MappingNode, "ssh": yaml.MappingNode,
+	"ssh_authorized_keys": yaml.SequenceNode, "ssh_deletekeys": yaml.ScalarNode,
+	"ssh_fp_console_blacklist": yaml.SequenceNode, "ssh_genkeytypes": yaml.SequenceNode, "ssh_import_id": yaml.SequenceNode,
+	"ssh_key_console_blacklist": yaml.SequenceNode, "ssh_keys": yaml.MappingNode, "ssh_publish_hostkeys": yaml.MappingNode,
+	"ssh_pwauth": yaml.ScalarNode, "ssh_quiet_keygen": yaml.ScalarNode, "swap": yaml.MappingNode,
+	"system_info": yaml.MappingNode, "timezone": yaml.ScalarNode, "ubuntu_advantage": yaml.MappingNode,
+	"ubuntu_pro": yaml.MappingNode, "updates": yaml.MappingNode, "user": 0, "users": 0, "vendor_data": yaml.MappingNode,
+	"wireguard": yaml.MappingNode, "write_files": yaml.SequenceNode, "yum_repos": yaml.MappingNode,
+	"zypper": yaml.MappingNode,
+}
+
+var cloudConfigUserKeys = map[string]bool{
+	"name": true, "gecos": true, "groups": true, "homedir": true, "inactive": true, "lock_passwd": true,
+	"lock-passwd": true, "no_create_home": true, "no_log_init": true, "no_user_group": true, "passwd": true,
+	"hashed_passwd": true, "plain_text_passwd": true, "create_groups": true, "primary_group": true,
+	"selinux_user": true, "shell": true, "snapuser": true, "ssh_authorized_keys": true, "ssh-authorized-keys": true,
+	"ssh_import_id": true, "ssh_redirect_user": true, "system": true, "sudo": true, "uid": true, "doas": true,
+	"expiredate": true,
+}
+
+var cloudConfigWriteFileKeys = map[string]bool{
+	"path": true, "content": true, "source": true, "owner": true, "permissions": true, "encoding": true,
+	"append": true, "defer": true,
+}
+
+var yamlKindNames = map[yaml.Kind]string{
+	yaml.MappingNode:  "a map",
+	yaml.SequenceNode: "a list",
+	yaml.ScalarNode:   "a scalar",
+}
+
+// cloudConfigError is a problem found in a line of the rendered user data. The template is merged and marshaled again
+// when it is rendered, so the line is not the one of the template
+type cloudConfigError struct {
+	Line int
+	Msg  string
+}
+
+// cloudConfigErrors are all the problems found in the user data
+type cloudConfigErrors []cloudConfigError
+
+func (e cloudConfigErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, err := range e {
+		lines = append(lines, fmt.Sprintf("  rendered line %d: %s", err.Line, err.Msg))
+	}
+	return "invalid cloud-config, run tanzu jumpbox userdata render to see the rendered lines:\n" + strings.Join(lines, "\n")
+}
+
+// validateUserData validates the rendered user data. Cloud-configs are checked against the cloud-config schema,
+// multipart documents have each cloud-config part checked and other formats, like shell scripts, are accepted as is
+func validateUserData(doc []byte) error {
+	switch {
+	case isCloudConfig(doc):
+		return validateCloudConfig(doc)
+	case strings.HasPrefix(string(doc), "Content-Type: multipart/"):
+		return validateMultipart(doc)
+	case strings.HasPrefix(string(doc), "#"):
+		return nil
+	default:
+		return cloudConfigErrors{{Line: 1, Msg: fmt.Sprintf("user data must start with %s or a #! script line", cloudConfigHeader)}}
+	}
+}
+
+// validateCloudConfig parses a cloud-config and checks known keys and their types, returning all problems found
+func validateCloudConfig(doc []byte) error {
+	if !strings.HasPrefix(string(doc), cloudConfigHeader) {
+		return cloudConfigErrors{{Line: 1, Msg: fmt.Sprintf("%s must be the first line", cloudConfigHeader)}}
+	}
+
+	root := &yaml.Node{}
+	err := yaml.Unmarshal(doc, root)
+	if err != nil {
+		return errors.Wrap(err, "invalid cloud-config")
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+	config := root.Content[0]
+	if config.Kind != yaml.MappingNode {
+		return cloudConfigErrors{{Line: config.Line, Msg: "cloud-config must be a map"}}
+	}
+
+	var errs cloudConfigErrors
+	for i := 0; i+1 < len(config.Content); i += 2 {
+		key, value := config.Content[i], config.Content[i+1]
+		kind, ok := cloudConfigKeys[key.Value]
+		if !ok {
+			errs = append(errs, cloudConfigError{Line: key.Line, Msg: fmt.Sprintf("unknown key %q", key.Value)})
+			continue
+		}
+		if kind != 0 && value.Kind != kind {
+			errs = append(errs, cloudConfigError{Line: value.Line, Msg: fmt.Sprintf("%s must be %s", key.Value, yamlKindNames[kind])})
+			continue
+		}
+		switch key.Value {
+		case "users":
+			errs = append(errs, validateListOfMaps(value, "users", cloudConfigUserKeys, "name", true)...)
+		case "write_files":
+			errs = append(errs, validateListOfMaps(value, "write_files", cloudConfigWriteFileKeys, "path", false)...)
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return errs
+	}
+	return nil
+}
+
+// validateListOfMaps checks each entry of a list against its known keys and required key.
+// allowScalar accepts plain string entries, like `default` in users
+func validateListOfMaps(list *yaml.Node, name string, keys map[string]bool, required string, allowScalar bool) cloudConfigErrors {
+	if list.Kind != yaml.SequenceNode {
+		if allowScalar && list.Kind == yaml.ScalarNode {
+			return nil
+		}
+		return cloudConfigErrors{{Line: list.Line, Msg: fmt.Sprintf("%s must be a list", name)}}
+	}
+
+	var errs cloudConfigErrors
+	for _, entry := range list.Content {
+		if entry.Kind == yaml.ScalarNode && allowScalar {
+			continue
+		}
+		if entry.Kind != yaml.MappingNode {
+			errs = append(errs, cloudConfigError{Line: entry.Line, Msg: fmt.Sprintf("%s entries must be maps", name)})
+			continue
+		}
+		found := false
+		for i := 0; i+1 < len(entry.Content); i += 2 {
+			key := entry.Content[i]
+			if key.Value == required {
+				found = true
+			}
+			if !keys[key.Value] {
+				errs = append(errs, cloudConfigError{Line: key.Line, Msg: fmt.Sprintf("unknown %s key %q", name, key.Value)})
+			}
+		}
+		if !found {
+			errs = append(errs, cloudConfigError{Line: entry.Line, Msg: fmt.Sprintf("%s entry is missing %q", name, required)})
+		}
+	}
+	return errs
+}
+
+// validateMultipart validates the cloud-config parts of a MIME multipart user data
+func validateMultipart(doc []byte) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(doc))
+	if err != nil {
+		return errors.Wrap(err, "invalid multipart user data")
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return errors.Wrap(err, "invalid multipart user data")
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "invalid multipart user data")
+		}
+		if !strings.HasPrefix(part.Header.Get("Content-Type"), "text/cloud-config") {
+			continue
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return errors.Wrap(err, "invalid multipart user data")
+		}
+		err = validateCloudConfig(data)
+		if err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("part %d", i))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_validateUserData(t *testing.T) {
+	tests := []struct {
+		name      string
+		doc       string
+		wantErr   bool
+		wantLines []int
+	}{
+		{
+			name: "valid",
+			doc:  "#cloud-config\npackages:\n  - git\nusers:\n  - default\n  - name: alice\n    shell: /bin/bash\n",
+		},
+		{
+			name: "shell-script",
+			doc:  "#!/bin/bash\necho hello\n",
+		},
+		{
+			name:    "tabs",
+			doc:     "#cloud-config\npackages:\n\t- git\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing-header",
+			doc:     "packages:\n  - git\n",
+			wantErr: true,
+		},
+		{
+			name:      "unknown-keys-and-types",
+			doc:       "#cloud-config\npackage:\n  - git\nruncmd: ls\nusers:\n  - shell: /bin/bash\nwrite_files:\n  - content: hello\n    mode: '0644'\n",
+			wantErr:   true,
+			wantLines: []int{2, 4, 6, 8, 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUserData([]byte(tt.doc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateUserData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(tt.wantLines) == 0 {
+				return
+			}
+			errs, ok := err.(cloudConfigErrors)
+			if !ok {
+				t.Fatalf("validateUserData() error type %T, want cloudConfigErrors", err)
+			}
+			if len(errs) != len(tt.wantLines) {
+				t.Fatalf("validateUserData() got %d errors, want %d: %v", len(errs), len(tt.wantLines), err)
+			}
+			for i, line := range tt.wantLines {
+				if errs[i].Line != line {
+					t.Errorf("validateUserData() error %d at line %d, want %d", i, errs[i].Line, line)
+				}
+			}
+		})
+	}
+}
+
+func Test_validateBuiltinTemplates(t *testing.T) {
+	tanzuDir := t.TempDir()
+	setOptions(t, &VMOptions{tanzuDir: tanzuDir})
+	pinBuiltinTools(t)
+	templates, err := listTemplates()
+	if err != nil {
+		t.Fatalf("listTemplates() error = %v", err)
+	}
+	for _, name := range templateNames(templates) {
+		t.Run(name, func(t *testing.T) {
+			setOptions(t, &VMOptions{
+				Name:         "jumpbox-1",
+				User:         "operator",
+				Groups:       "sudo",
+				Shell:        "/bin/bash",
+				Sudo:         sudoNoPasswd,
+				Template:     name,
+				SSHPublicKey: placeholderSSHPublicKey,
+				AccessUsers:  []AccessUser{{Name: "alice", Keys: []string{"ssh-rsa AAAA"}}},
+				tanzuDir:     tanzuDir,
+			})
+			doc, err := renderUserdataDoc()
+			if err != nil {
+				t.Fatalf("renderUserdataDoc() error = %v", err)
+			}
+			if err := validateUserData(doc); err != nil {
+				t.Errorf("template %s is invalid: %v\n%s", name, err, doc)
+			}
+			if !strings.Contains(string(doc), "name: alice") {
+				t.Errorf("template %s is missing access users", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vmware-tanzu/tanzu-framework/pkg/v1/cli/component"
+	"golang.org/x/term"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// createRequiredFlags are the create flags without a default. The wizard asks for the missing ones
+var createRequiredFlags = []string{"namespace", "image", "class", "storage-class", "network-type"}
+
+// choice is a pick-list option of the wizard, shown with its label
+type choice struct {
+	value string
+	label string
+}
+
+// prompt asks a question in the terminal, on stderr like the rest of the wizard
+var prompt = func(config *component.PromptConfig, response interface{}) error {
+	return component.Prompt(config, response, component.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+}
+
+// interactive reports whether create can prompt for the missing flags
+var interactive = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// completeCreateFlags applies the --profile, or the current profile, and the config defaults. When the name or required
+// flags are still missing and stdin is a terminal, it runs the create wizard. It returns the jumpbox name
+func completeCreateFlags(ctx context.Context, cmd *cobra.Command, args []string) (string, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	profileName := options.profile
+	if profileName == "" {
+		profileName = config.CurrentProfile
+	}
+	if profileName != "" {
+		profile, err := config.profile(profileName)
+		if err != nil {
+			return "", err
+		}
+		err = profile.applyTo(cmd)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = config.Defaults.applyTo(cmd)
+	if err != nil {
+		return "", err
+	}
+	err = checkIntentFlags(cmd)
+	if err != nil {
+		return "", err
+	}
+	if options.Namespace != "" {
+		err = resolveIntent(ctx, cmd)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	missing := missingFlags(cmd)
+	if name != "" && len(missing) == 0 {
+		return name, nil
+	}
+	if !interactive() {
+		if name == "" {
+			return "", errors.New("jumpbox name is required")
+		}
+		return "", errors.Errorf(`required flag(s) "%s" not set`, strings.Join(missing, `", "`))
+	}
+	return runWizard(ctx, cmd, name)
+}
+
+func missingFlags(cmd *cobra.Command) []string {
+	var missing []string
+	for _, name := range createRequiredFlags {
+		// --nic sets the network type
+		if name == "network-type" && len(options.nicFlags) > 0 {
+			continue
+		}
+		if cmd.Flags().Lookup(name).Value.String() == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// runWizard prompts for the create settings not given, with pick-lists of what the namespace can use, shows a
+// summary to confirm and optionally saves the answers as a profile. It writes to stderr, so stdout only has the
+// create output, like the --dry-run manifest
+func runWizard(ctx context.Context, cmd *cobra.Command, name string) (string, error) {
+	_, _ = fmt.Fprintln(os.Stderr, "Answer the questions to create the jumpbox. Press Ctrl+C to cancel")
+	var err error
+	if name == "" {
+		err = prompt(&component.PromptConfig{Message: "Jumpbox name", Default: "jumpbox"}, &name)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = askFlag(cmd, "namespace", "vSphere namespace", nil)
+	if err != nil {
+		return "", err
+	}
+	err = resolveIntent(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	var images []choice
+	if list, err := listImages(ctx); err == nil {
+		for _, image := range list {
+			images = append(images, choice{value: image.Name, label: fmt.Sprintf("%s (%s %s)", image.Name, image.OS, image.Version)})
+		}
+	}
+	err = askFlag(cmd, "image", "VM image", images)
+	if err != nil {
+		return "", err
+	}
+
+	var classes []choice
+	if list, err := listClasses(ctx); err == nil {
+		for _, class := range list {
+			classes = append(classes, choice{value: class.Name, label: fmt.Sprintf("%s (%d CPUs, %s)", class.Name, class.CPUs, class.Memory)})
+		}
+	}
+	err = askFlag(cmd, "class", "VM class", classes)
+	if err != nil {
+		return "", err
+	}
+
+	var storageClasses []choice
+	if list, err := listStorageClasses(ctx); err == nil {
+		for _, sc := range list {
+			storageClasses = append(storageClasses, choice{value: sc.Name, label: fmt.Sprintf("%s (%s of %s used)", sc.Name, sc.Used, sc.Limit)})
+		}
+	}
+	err = askFlag(cmd, "storage-class", "Storage class", storageClasses)
+	if err != nil {
+		return "", err
+	}
+
+	networks, _ := listNetworks(ctx)
+	err = askFlag(cmd, "network-type", "Network type", networkTypeChoices(networks))
+	if err != nil {
+		return "", err
+	}
+	if options.NetworkType == networkTypeVDS {
+		var names []choice
+		for _, network := range networks {
+			if network.Type == networkTypeVDS {
+				names = append(names, choice{value: network.Name, label: network.Name})
+			}
+		}
+		err = askFlag(cmd, "network-name", "Network", names)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = askFlag(cmd, "user", "User", nil)
+	if err != nil {
+		return "", err
+	}
+	err = askFlag(cmd, "disk-size", "Workspace disk size", nil)
+	if err != nil {
+		return "", err
+	}
+
+	printWizardSummary(name)
+	confirm := ""
+	err = prompt(&component.PromptConfig{Message: "Continue?", Options: []string{"yes", "no"}, Default: "yes"}, &confirm)
+	if err != nil {
+		return "", err
+	}
+	if confirm != "yes" {
+		return "", errors.New("create cancelled")
+	}
+
+	profileName := ""
+	err = prompt(&component.PromptConfig{Message: "Save the answers as a profile? Profile name, empty to skip"}, &profileName)
+	if err != nil {
+		return "", err
+	}
+	if profileName != "" {
+		err = saveProfile(profileName, createProfile())
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Saved profile %s. Use it with `tanzu jumpbox create <name> --profile %s`\n", profileName, profileName)
+	}
+	return name, nil
+}
+
+// askFlag prompts for a flag not given, with its current value as the default, and sets it. choices are a pick-list,
+// free text is asked when there are none
+func askFlag(cmd *cobra.Command, flag string, message string, choices []choice) error {
+	if cmd.Flags().Changed(flag) {
+		return nil
+	}
+	current := cmd.Flags().Lookup(flag).Value.String()
+
+	answer := ""
+	if len(choices) == 0 {
+		err := prompt(&component.PromptConfig{Message: message, Default: current}, &answer)
+		if err != nil {
+			return err
+		}
+	} else {
+		labels := make([]string, 0, len(choices))
+		defaultLabel := ""
+		for _, c := range choices {
+			labels = append(labels, c.label)
+			if c.value == current {
+				defaultLabel = c.label
+			}
+		}
+		label := ""
+		err := prompt(&component.PromptConfig{Message: message, Options: labels, Default: defaultLabel}, &label)
+		if err != nil {
+			return err
+		}
+		for _, c := range choices {
+			if c.label == label {
+				answer = c.value
+			}
+		}
+	}
+	if answer == "" {
+		return errors.Errorf("%s is required", flag)
+	}
+	return cmd.Flags().Set(flag, answer)
+}
+
+// networkTypeChoices offers the network types of the namespace networks first
+func networkTypeChoices(networks []NetworkInfo) []choice {
+	choices := []choice{
+		{value: networkTypeNSXT, label: networkTypeNSXT},
+		{value: networkTypeVDS, label: networkTypeVDS},
+	}
+	for _, network := range networks {
+		if network.Type == networkTypeVDS {
+			choices[0], choices[1] = choices[1], choices[0]
+			break
+		}
+	}
+	return choices
+}
+
+func printWizardSummary(name string) {
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 1, ' ', 0)
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintf(w, "Name:\t%s\n", name)
+	_, _ = fmt.Fprintf(w, "Namespace:\t%s\n", options.Namespace)
+	_, _ = fmt.Fprintf(w, "Image:\t%s\n", options.ImageName)
+	_, _ = fmt.Fprintf(w, "Class:\t%s\n", options.ClassName)
+	_, _ = fmt.Fprintf(w, "Storage Class:\t%s\n", options.StorageClassName)
+	_, _ = fmt.Fprintf(w, "Network:\t%s %s\n", options.NetworkType, options.NetworkName)
+	_, _ = fmt.Fprintf(w, "User:\t%s\n", options.User)
+	_, _ = fmt.Fprintf(w, "Disk:\t%s\n", options.Disk.Size)
+	_ = w.Flush()
+}
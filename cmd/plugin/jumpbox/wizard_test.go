@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"github.com/vmware-tanzu/tanzu-framework/pkg/v1/cli/component"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1/install"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	simpleFake "k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_completeCreateFlags(t *testing.T) {
+	ctx := context.Background()
+	interactive = func() bool { return false }
+	tanzuDir := t.TempDir()
+	setOptions(t, &VMOptions{tanzuDir: tanzuDir})
+	err := saveProfile("team", &Profile{Namespace: "dev", ImageName: "ubuntu-2004", ClassName: "best-effort-small", StorageClassName: "gold", NetworkType: networkTypeNSXT, User: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		flags    []string
+		wantErr  string
+		wantUser string
+	}{
+		{
+			name:    "missing-flags",
+			args:    []string{"jumpbox-1"},
+			flags:   []string{"--namespace", "dev", "--class", "best-effort-small"},
+			wantErr: `required flag(s) "image", "storage-class", "network-type" not set`,
+		},
+		{
+			name:    "missing-name",
+			wantErr: "jumpbox name is required",
+		},
+		{
+			name:     "profile",
+			args:     []string{"jumpbox-1"},
+			flags:    []string{"--profile", "team"},
+			wantUser: "alice",
+		},
+		{
+			name:     "flags-override-profile",
+			args:     []string{"jumpbox-1"},
+			flags:    []string{"--profile", "team", "--user", "bob"},
+			wantUser: "bob",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, &VMOptions{tanzuDir: tanzuDir})
+			cmd := newCreateCmd(ctx)
+			if err := cmd.ParseFlags(tt.flags); err != nil {
+				t.Fatal(err)
+			}
+			name, err := completeCreateFlags(ctx, cmd, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("completeCreateFlags() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("completeCreateFlags() error = %v", err)
+			}
+			if name != "jumpbox-1" || options.User != tt.wantUser || options.ImageName != "ubuntu-2004" {
+				t.Errorf("completeCreateFlags() got %s %+v", name, options)
+			}
+		})
+	}
+}
+
+func Test_runWizard(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	setDynamicClient(t, fake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{
+		gvrNSXNetwork: "VirtualNetworkList",
+		gvrVDSNetwork: "NetworkList",
+	},
+		&v1alpha1.ContentSourceBinding{
+			TypeMeta:         v1.TypeMeta{Kind: "ContentSourceBinding", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta:       v1.ObjectMeta{Name: "library", Namespace: "dev"},
+			ContentSourceRef: v1alpha1.ContentSourceReference{Name: "library"},
+		},
+		&v1alpha1.ContentSource{
+			TypeMeta:   v1.TypeMeta{Kind: "ContentSource", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: "library"},
+			Spec:       v1alpha1.ContentSourceSpec{ProviderRef: v1alpha1.ContentProviderReference{Name: "library"}},
+		},
+		&v1alpha1.VirtualMachineImage{
+			TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineImage", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: "ubuntu-2004"},
+			Spec: v1alpha1.VirtualMachineImageSpec{
+				ProviderRef: v1alpha1.ContentProviderReference{Name: "library"},
+				ProductInfo: v1alpha1.VirtualMachineImageProductInfo{Product: "Ubuntu", FullVersion: "20.04"},
+			},
+		},
+		&v1alpha1.VirtualMachineClassBinding{
+			TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineClassBinding", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: "best-effort-small", Namespace: "dev"},
+			ClassRef:   v1alpha1.ClassReference{Name: "best-effort-small"},
+		},
+		&v1alpha1.VirtualMachineClass{
+			TypeMeta:   v1.TypeMeta{Kind: "VirtualMachineClass", APIVersion: "vmoperator.vmware.com/v1alpha1"},
+			ObjectMeta: v1.ObjectMeta{Name: "best-effort-small"},
+			Spec: v1alpha1.VirtualMachineClassSpec{
+				Hardware: v1alpha1.VirtualMachineClassHardware{Cpus: 2, Memory: resource.MustParse("4Gi")},
+			},
+		},
+	))
+	setClient(t, simpleFake.NewSimpleClientset(&corev1.ResourceQuota{
+		ObjectMeta: v1.ObjectMeta{Name: "dev-storagequota", Namespace: "dev"},
+		Spec:       corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{"gold" + storageClassQuotaSuffix: resource.MustParse("1Ti")}},
+	}))
+
+	answers := map[string]string{
+		"vSphere namespace":   "dev",
+		"VM image":            "ubuntu-2004 (Ubuntu 20.04)",
+		"VM class":            "best-effort-small (2 CPUs, 4Gi)",
+		"Storage class":       "gold (0 of 1Ti used)",
+		"Network type":        networkTypeNSXT,
+		"User":                "alice",
+		"Workspace disk size": "64Gi",
+		"Continue?":           "yes",
+		"Save the answers as a profile? Profile name, empty to skip": "team",
+	}
+	prompt = func(config *component.PromptConfig, response interface{}) error {
+		answer, ok := answers[config.Message]
+		if !ok {
+			t.Fatalf("unexpected question %q", config.Message)
+		}
+		if len(config.Options) > 0 && !strings.Contains(strings.Join(config.Options, "\n"), answer) {
+			t.Fatalf("question %q options %v don't include %q", config.Message, config.Options, answer)
+		}
+		*response.(*string) = answer
+		return nil
+	}
+	interactive = func() bool { return true }
+
+	setOptions(t, &VMOptions{tanzuDir: t.TempDir()})
+	cmd := newCreateCmd(ctx)
+	name, err := completeCreateFlags(ctx, cmd, []string{"jumpbox-1"})
+	if err != nil {
+		t.Fatalf("completeCreateFlags() error = %v", err)
+	}
+	if name != "jumpbox-1" {
+		t.Errorf("completeCreateFlags() name = %s", name)
+	}
+
+	want := &Profile{
+		Namespace:        "dev",
+		ImageName:        "ubuntu-2004",
+		ClassName:        "best-effort-small",
+		StorageClassName: "gold",
+		NetworkType:      networkTypeNSXT,
+		User:             "alice",
+		Template:         options.Template,
+		DiskSize:         "64Gi",
+	}
+	got, err := loadProfile("team")
+	if err != nil {
+		t.Fatalf("loadProfile() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("saved profile %+v, want %+v", got, want)
+	}
+}